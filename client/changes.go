@@ -0,0 +1,124 @@
+/*
+Copyright Alex Mack and Michael Lawson (michael@sphinix.com)
+This file is part of Orca.
+
+Orca is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Orca is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Orca.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package client
+
+import (
+	"fmt"
+	"orcahostd/model"
+	"time"
+)
+
+/* A failed change is retried when the trainer sends it again, up to this many attempts */
+const MaxChangeAttempts = 3
+
+/* ChangeError tells which phase of applying a change failed */
+type ChangeError struct {
+	Phase string
+	Err   error
+}
+
+func (err *ChangeError) Error() string {
+	return fmt.Sprintf("%s failed: %s", err.Phase, err.Err)
+}
+
+func phaseError(phase string, err error) *ChangeError {
+	return &ChangeError{Phase: phase, Err: err}
+}
+
+/* Records a change we have not seen before as pending. Returns false if the change
+should not be handed to the executor, because it is already queued, done or out of attempts. */
+func (client *Client) admitChange(id string) bool {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	result, ok := client.Changes[id]
+	if ok && !(result.Status == model.ChangeFailed && result.Attempts < MaxChangeAttempts) {
+		return false
+	}
+
+	result.Status = model.ChangePending
+	client.Changes[id] = result
+	return true
+}
+
+/* Undoes admitChange for a change the executor did not take. This happens when the change
+already finished as failed but the executor is still wrapping it up, leaving it pending would
+keep it from ever being admitted again. */
+func (client *Client) rejectChange(id string) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if result, ok := client.Changes[id]; ok && result.Status == model.ChangePending {
+		result.Status = model.ChangeFailed
+		client.Changes[id] = result
+	}
+}
+
+func (client *Client) startChange(id string) {
+	client.mutex.Lock()
+	result := client.Changes[id]
+	result.Status = model.ChangeApplying
+	result.Error = ""
+	result.Phase = ""
+	result.Started = time.Now()
+	result.Finished = time.Time{}
+	result.Attempts++
+	client.Changes[id] = result
+	client.mutex.Unlock()
+	client.persist()
+}
+
+func (client *Client) finishChange(change model.Change, err error) {
+	rolledBack := err != nil && client.RolledBack(change.Name, change.Id)
+
+	client.mutex.Lock()
+	result := client.Changes[change.Id]
+	result.Finished = time.Now()
+	if err == nil {
+		result.Status = model.ChangeSucceeded
+	} else {
+		result.Status = model.ChangeFailed
+		if rolledBack {
+			/* The app is back on its previous version, the change is not retried */
+			result.Status = model.ChangeRolledBack
+		}
+		result.Error = err.Error()
+		if changeErr, ok := err.(*ChangeError); ok {
+			result.Phase = changeErr.Phase
+			result.Error = changeErr.Err.Error()
+		}
+	}
+	client.Changes[change.Id] = result
+	client.mutex.Unlock()
+	client.persist()
+
+	ClientLogger.Infof("Change %s (%s) for app %s finished: %s %s", change.Id, change.Type, change.Name, result.Status, result.Error)
+}
+
+/* A copy of the results of every change we have seen */
+func (client *Client) GetChangeLog() map[string]model.ChangeResult {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	ret := make(map[string]model.ChangeResult)
+	for id, result := range client.Changes {
+		ret[id] = result
+	}
+	return ret
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"orcahostd/model"
+	"strings"
+	"testing"
+)
+
+func TestChanges_FailedCreate_ReportedAndRetried(t *testing.T) {
+	engine := newFakeEngine()
+	engine.failCreate["2"] = true
+	client, cleanup := newTestClient(t, engine)
+	defer cleanup()
+
+	change := model.Change{Id: "c1", Type: "add_application", Name: "app1", AppConfig: model.VersionConfig{Version: "2"}}
+	for attempt := 1; attempt <= MaxChangeAttempts+1; attempt++ {
+		client.HandleRequestedChanges([]model.Change{change})
+		waitForChanges(t, client)
+	}
+
+	result := client.GetChangeLog()["c1"]
+	if result.Status != model.ChangeFailed || result.Phase != model.PhaseCreate || result.Error != "create failed" {
+		t.Error(result)
+	}
+	if result.Attempts != MaxChangeAttempts {
+		t.Error(result.Attempts)
+	}
+	if result.Started.IsZero() || result.Finished.Before(result.Started) {
+		t.Error(result)
+	}
+}
+
+func TestChanges_FailedDeployWithAutoRollback_RolledBack(t *testing.T) {
+	engine := newFakeEngine()
+	engine.failCreate["2"] = true
+	client, cleanup := newTestClient(t, engine)
+	defer cleanup()
+
+	client.HandleRequestedChanges([]model.Change{
+		{Id: "c1", Type: "add_application", Name: "app1", AppConfig: model.VersionConfig{Version: "1"}},
+		{Id: "c2", Type: "add_application", Name: "app1", AppConfig: model.VersionConfig{Version: "2", AutoRollback: true}},
+	})
+	waitForChanges(t, client)
+
+	results := client.GetChangeLog()
+	if results["c1"].Status != model.ChangeSucceeded {
+		t.Error(results["c1"])
+	}
+	if results["c2"].Status != model.ChangeRolledBack || results["c2"].Phase != model.PhaseCreate {
+		t.Error(results["c2"])
+	}
+
+	states := client.GetAppState()
+	if len(states) != 1 || states[0].Application.Version != "1" || states[0].Application.Rollback == nil {
+		t.Fatal(states)
+	}
+	if states[0].Application.Rollback.FailedVersion != "2" {
+		t.Error(states[0].Application.Rollback)
+	}
+}
+
+func TestChanges_FailedRollback_Failed(t *testing.T) {
+	engine := newFakeEngine()
+	client, cleanup := newTestClient(t, engine)
+	defer cleanup()
+
+	client.HandleRequestedChanges([]model.Change{{Id: "c1", Type: "add_application", Name: "app1", AppConfig: model.VersionConfig{Version: "1"}}})
+	waitForChanges(t, client)
+
+	/* Neither the new nor the previous version can be created anymore */
+	engine.mutex.Lock()
+	engine.failCreate["1"] = true
+	engine.failCreate["2"] = true
+	engine.mutex.Unlock()
+	client.HandleRequestedChanges([]model.Change{{Id: "c2", Type: "add_application", Name: "app1", AppConfig: model.VersionConfig{Version: "2", AutoRollback: true}}})
+	waitForChanges(t, client)
+
+	result := client.GetChangeLog()["c2"]
+	if result.Status != model.ChangeFailed || result.Phase != model.PhaseCreate || !strings.Contains(result.Error, "rolling back to 1 failed") {
+		t.Error(result)
+	}
+	for _, state := range client.GetAppState() {
+		if state.Application.Rollback != nil {
+			t.Error("failed rollback was recorded", state.Application.Rollback)
+		}
+	}
+}
+
+func TestChanges_InvalidAddApplication_KeepsRunningApp(t *testing.T) {
+	engine := newFakeEngine()
+	client, cleanup := newTestClient(t, engine)
+	defer cleanup()
+
+	client.HandleRequestedChanges([]model.Change{{Id: "c1", Type: "add_application", Name: "app1", AppConfig: model.VersionConfig{Version: "1"}}})
+	waitForChanges(t, client)
+	old, err := client.GetAppStateIndividual("app1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := model.VersionConfig{Version: "2", Labels: map[string]string{"orca.app": "other"}}
+	client.HandleRequestedChanges([]model.Change{{Id: "c2", Type: "add_application", Name: "app1", AppConfig: invalid}})
+	waitForChanges(t, client)
+
+	if result := client.GetChangeLog()["c2"]; result.Status != model.ChangeFailed || result.Phase != model.PhaseCreate {
+		t.Error(result)
+	}
+	state, err := client.GetAppStateIndividual("app1")
+	if err != nil || state.DockerAppId != old.DockerAppId || !engine.QueryApp(old.DockerAppId) {
+		t.Error("running app was removed", state, err)
+	}
+}
@@ -0,0 +1,332 @@
+/*
+Copyright Alex Mack and Michael Lawson (michael@sphinix.com)
+This file is part of Orca.
+
+Orca is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Orca is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Orca.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"io"
+	"net"
+	"net/http"
+	"orcahostd/model"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	defaultCheckTimeout = 5 * time.Second
+	defaultDeployCheckInterval = 6 * time.Second
+	defaultDeployCheckAttempts = 10
+	/* Only this much of a response body is matched against */
+	maxCheckBodySize = 1024 * 1024
+	/* Only the end of the output of exec checks is kept */
+	maxCheckOutputSize = 4096
+	/* How long a deploy waits for the HEALTHCHECK of an image to leave the starting state */
+	dockerHealthTimeout = 5 * time.Minute
+	dockerHealthPollInterval = 2 * time.Second
+)
+
+func checkTimeout(check model.ApplicationChecks) time.Duration {
+	if check.Timeout > 0 {
+		return time.Duration(check.Timeout) * time.Second
+	}
+	return defaultCheckTimeout
+}
+
+func successThreshold(check model.ApplicationChecks) int {
+	if check.SuccessThreshold > 0 {
+		return check.SuccessThreshold
+	}
+	return 1
+}
+
+/* Whether the status code matches one of the accepted codes or ranges */
+func statusAccepted(accepted []string, status int) bool {
+	if len(accepted) == 0 {
+		return status == 200
+	}
+	for _, value := range accepted {
+		parts := strings.SplitN(value, "-", 2)
+		from, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+		if err != nil {
+			continue
+		}
+		to := from
+		if len(parts) == 2 {
+			if to, err = strconv.Atoi(strings.TrimSpace(parts[1])); err != nil {
+				continue
+			}
+		}
+		if status >= from && status <= to {
+			return true
+		}
+	}
+	return false
+}
+
+func runHttpCheck(check model.ApplicationChecks) error {
+	method := check.Method
+	if method == "" {
+		method = "GET"
+	}
+	req, err := http.NewRequest(method, check.Goal, nil)
+	if err != nil {
+		return err
+	}
+	for key, value := range check.Headers {
+		if strings.EqualFold(key, "Host") {
+			req.Host = value
+		} else {
+			req.Header.Set(key, value)
+		}
+	}
+
+	httpClient := &http.Client{Timeout: checkTimeout(check)}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if !statusAccepted(check.StatusCodes, res.StatusCode) {
+		return fmt.Errorf("Unexpected status %d from %s", res.StatusCode, check.Goal)
+	}
+	if check.BodyContains == "" && check.BodyRegex == "" {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, maxCheckBodySize))
+	if err != nil {
+		return err
+	}
+	if check.BodyContains != "" && !strings.Contains(string(body), check.BodyContains) {
+		return fmt.Errorf("Response from %s does not contain %q", check.Goal, check.BodyContains)
+	}
+	if check.BodyRegex != "" {
+		matcher, err := regexp.Compile(check.BodyRegex)
+		if err != nil {
+			return err
+		}
+		if !matcher.Match(body) {
+			return fmt.Errorf("Response from %s does not match %q", check.Goal, check.BodyRegex)
+		}
+	}
+	return nil
+}
+
+/* Runs the command of the check inside the container, the exit code decides */
+func (client *Client) runExecCheck(appId string, check model.ApplicationChecks) (string, error) {
+	command := check.Command
+	if len(command) == 0 {
+		command = []string{"/bin/sh", "-c", check.Goal}
+	}
+
+	result, err := client.engine.ExecApp(appId, command, checkTimeout(check))
+	output := result.Output
+	if len(output) > maxCheckOutputSize {
+		output = output[len(output) - maxCheckOutputSize:]
+	}
+	if err != nil {
+		return output, err
+	}
+	if result.ExitCode != 0 {
+		return output, fmt.Errorf("Command exited with %d", result.ExitCode)
+	}
+	return output, nil
+}
+
+/* Runs a single attempt of the check against the instance, without resolving its goal */
+func (client *Client) attemptCheck(appId string, check model.ApplicationChecks) (string, error) {
+	switch strings.ToLower(check.Type) {
+	case "http":
+		return "", runHttpCheck(check)
+	case "tcp":
+		socket, err := net.DialTimeout("tcp", check.Goal, checkTimeout(check))
+		if err != nil {
+			return "", err
+		}
+		return "", socket.Close()
+	case "exec":
+		return client.runExecCheck(appId, check)
+	}
+	return "", nil
+}
+
+/* Runs a single attempt of the check against the instance */
+func (client *Client) runCheck(appId string, check model.ApplicationChecks) model.CheckResult {
+	result := model.CheckResult{Type: check.Type, Goal: check.Goal, Time: time.Now()}
+
+	goal, err := client.resolveGoal(appId, check.Goal)
+	if err == nil {
+		check.Goal = goal
+		result.Goal = goal
+		result.Output, err = client.attemptCheck(appId, check)
+	}
+
+	result.Latency = int64(time.Since(result.Time) / time.Millisecond)
+	result.Passed = err == nil
+	if err != nil {
+		result.Error = err.Error()
+		ClientLogger.Debugf("Check %s %s of %s failed: %s", check.Type, check.Goal, appId, err)
+	}
+	return result
+}
+
+/* Runs every check of the instance once */
+func (client *Client) RunCheck(appId string, config model.VersionConfig) bool {
+	for _, check := range config.Checks {
+		if !client.runCheck(appId, check).Passed {
+			return false
+		}
+	}
+	return true
+}
+
+/* Runs one check until it passed SuccessThreshold times in a row or failed
+FailureThreshold times, returns the last result */
+func (client *Client) awaitCheck(appId string, check model.ApplicationChecks) model.CheckResult {
+	attempts := check.FailureThreshold
+	if attempts < 1 {
+		attempts = defaultDeployCheckAttempts
+	}
+	interval := defaultDeployCheckInterval
+	if check.Interval > 0 {
+		interval = time.Duration(check.Interval) * time.Second
+	}
+
+	successes, failures := 0, 0
+	for {
+		result := client.runCheck(appId, check)
+		if result.Passed {
+			successes++
+			if successes >= successThreshold(check) {
+				return result
+			}
+		} else {
+			successes = 0
+			failures++
+			if failures >= attempts {
+				return result
+			}
+		}
+		time.Sleep(interval)
+	}
+}
+
+/* Waits for the HEALTHCHECK of the image, if it has one, to report healthy or unhealthy */
+func (client *Client) awaitDockerHealth(appId string) bool {
+	deadline := time.Now().Add(dockerHealthTimeout)
+	for {
+		info, err := client.engine.InspectApp(appId)
+		if err != nil {
+			return false
+		}
+		if info.Health == nil || info.Health.Status == model.DockerHealthHealthy {
+			return true
+		}
+		if info.Health.Status != model.DockerHealthStarting || time.Now().After(deadline) {
+			ClientLogger.Errorf("Docker health of %s is %s", appId, info.Health.Status)
+			return false
+		}
+		time.Sleep(dockerHealthPollInterval)
+	}
+}
+
+/* Waits for a freshly started instance to pass the HEALTHCHECK of its image and its startup
+checks, liveness and readiness checks are left to the monitor */
+func (client *Client) awaitChecks(state *model.ApplicationState, config model.VersionConfig) bool {
+	results := make([]model.CheckResult, 0)
+	passed := client.awaitDockerHealth(state.DockerAppId)
+	for _, check := range config.Checks {
+		if !passed {
+			break
+		}
+		if check.Kind == model.CheckLiveness || check.Kind == model.CheckReadiness {
+			continue
+		}
+		result := client.awaitCheck(state.DockerAppId, check)
+		results = append(results, result)
+		if !result.Passed {
+			ClientLogger.Errorf("Check %s %s of %s did not pass: %s", check.Type, check.Goal, state.DockerAppId, result.Error)
+			passed = false
+			break
+		}
+	}
+
+	/* The monitor takes over from here, also for failed instances so they can recover */
+	client.monitor.Watch(state.DockerAppId, config, passed, results)
+	if !passed {
+		client.setState(state, "checks_failed")
+		return false
+	}
+	client.setState(state, "running")
+	return true
+}
+
+/* What a check goal template can refer to */
+type goalTarget struct {
+	/* Address of the container */
+	IP string
+	HostId string
+	ports []model.PortMapping
+}
+
+/* The host port bound to the container port, which is given as 8080 or 8080/udp */
+func (target goalTarget) HostPort(containerPort string) (string, error) {
+	if !strings.Contains(containerPort, "/") {
+		containerPort += "/tcp"
+	}
+	for _, mapping := range target.ports {
+		mapped := mapping.ContainerPort
+		if !strings.Contains(mapped, "/") {
+			mapped += "/tcp"
+		}
+		if mapped == containerPort && mapping.HostPort != "" {
+			return mapping.HostPort, nil
+		}
+	}
+	return "", fmt.Errorf("Container port %s is not bound to a host port", containerPort)
+}
+
+/* Fills in the placeholders of a check goal from what docker currently reports about the instance */
+func (client *Client) resolveGoal(appId string, goal string) (string, error) {
+	if !strings.Contains(goal, "{{") {
+		return goal, nil
+	}
+	tmpl, err := template.New("goal").Option("missingkey=error").Parse(goal)
+	if err != nil {
+		return goal, err
+	}
+	info, err := client.engine.InspectApp(appId)
+	if err != nil {
+		return goal, err
+	}
+
+	var resolved bytes.Buffer
+	target := goalTarget{IP: info.IP, HostId: client.hostId, ports: info.Ports}
+	if err := tmpl.Execute(&resolved, target); err != nil {
+		return goal, err
+	}
+	return resolved.String(), nil
+}
@@ -0,0 +1,178 @@
+package client
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"orcahostd/model"
+	"testing"
+	"time"
+)
+
+func TestStatusAccepted(t *testing.T) {
+	cases := []struct {
+		accepted []string
+		status   int
+		want     bool
+	}{
+		{nil, 200, true},
+		{nil, 204, false},
+		{[]string{"200-299"}, 204, true},
+		{[]string{"200-299", "404"}, 404, true},
+		{[]string{"200-299"}, 301, false},
+		{[]string{"junk"}, 200, false},
+	}
+	for _, c := range cases {
+		if got := statusAccepted(c.accepted, c.status); got != c.want {
+			t.Error(c.accepted, c.status, got)
+		}
+	}
+}
+
+func TestRunCheck_Http(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "HEAD" && r.Method != "GET" {
+			w.WriteHeader(405)
+			return
+		}
+		if r.Header.Get("X-Check") != "" {
+			w.WriteHeader(204)
+			return
+		}
+		if r.URL.Path == "/slow" {
+			time.Sleep(2 * time.Second)
+		}
+		w.Write([]byte(`{"status": "ok", "version": "1.2.3"}`))
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		check model.ApplicationChecks
+		pass  bool
+	}{
+		{model.ApplicationChecks{Type: "http", Goal: server.URL}, true},
+		{model.ApplicationChecks{Type: "HTTP", Goal: server.URL, Method: "POST"}, false},
+		{model.ApplicationChecks{Type: "http", Goal: server.URL, Headers: map[string]string{"X-Check": "1"}}, false},
+		{model.ApplicationChecks{Type: "http", Goal: server.URL, Headers: map[string]string{"X-Check": "1"}, StatusCodes: []string{"200-299"}}, true},
+		{model.ApplicationChecks{Type: "http", Goal: server.URL, BodyContains: `"ok"`}, true},
+		{model.ApplicationChecks{Type: "http", Goal: server.URL, BodyContains: "fail"}, false},
+		{model.ApplicationChecks{Type: "http", Goal: server.URL, BodyRegex: `"version": "1\.\d+`}, true},
+		{model.ApplicationChecks{Type: "http", Goal: server.URL, BodyRegex: `"version": "2`}, false},
+		{model.ApplicationChecks{Type: "http", Goal: server.URL + "/slow", Timeout: 1}, false},
+	}
+	client := &Client{}
+	for _, c := range cases {
+		if result := client.runCheck("", c.check); result.Passed != c.pass {
+			t.Error(c.check, result)
+		}
+	}
+}
+
+func TestDeployApp_CheckFails_ReportsCheckPhase(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(503)
+	}))
+	defer server.Close()
+
+	client, cleanup := newTestClient(t, newFakeEngine())
+	defer cleanup()
+
+	config := model.VersionConfig{Version: "1", Checks: []model.ApplicationChecks{
+		{Type: "http", Goal: server.URL, FailureThreshold: 1},
+	}}
+	client.HandleRequestedChanges([]model.Change{{Id: "1", Type: "add_application", Name: "app1", AppConfig: config}})
+	waitForChanges(t, client)
+
+	result := client.GetChangeLog()["1"]
+	if result.Status != model.ChangeFailed || result.Phase != model.PhaseCheck {
+		t.Error(result)
+	}
+}
+
+func TestExecCheck_ExitCodeDecides(t *testing.T) {
+	engine := newFakeEngine()
+	client, cleanup := newTestClient(t, engine)
+	defer cleanup()
+
+	config := model.VersionConfig{Version: "1", Checks: []model.ApplicationChecks{
+		{Type: "exec", Goal: "pgrep worker", FailureThreshold: 1},
+	}}
+	client.HandleRequestedChanges([]model.Change{{Id: "1", Type: "add_application", Name: "app1", AppConfig: config}})
+	waitForChanges(t, client)
+
+	states := client.GetAppState()
+	if len(states) != 1 || states[0].Application.State != "running" {
+		t.Fatal(states)
+	}
+	results := states[0].Application.CheckResults
+	if len(results) != 1 || !results[0].Passed || results[0].Output != "/bin/sh -c pgrep worker" {
+		t.Error(results)
+	}
+
+	engine.mutex.Lock()
+	engine.execExitCode = 1
+	engine.mutex.Unlock()
+
+	client.HandleRequestedChanges([]model.Change{{Id: "2", Type: "restart_application", Name: "app1"}})
+	waitForChanges(t, client)
+
+	states = client.GetAppState()
+	results = states[0].Application.CheckResults
+	if states[0].Application.State != "checks_failed" || len(results) != 1 || results[0].Passed || results[0].Error == "" {
+		t.Error(states[0].Application)
+	}
+}
+
+func TestRunCheck_TemplatedGoal(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health/host1" {
+			w.WriteHeader(404)
+		}
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	engine := newFakeEngine()
+	client, cleanup := newTestClient(t, engine)
+	defer cleanup()
+	client.hostId = "host1"
+
+	config := model.VersionConfig{Version: "1", PortMappings: []model.PortMapping{{HostPort: port, ContainerPort: "8080/tcp"}}}
+	client.HandleRequestedChanges([]model.Change{{Id: "1", Type: "add_application", Name: "app1", AppConfig: config}})
+	waitForChanges(t, client)
+	id := client.GetAppState()[0].DockerAppId
+
+	result := client.runCheck(id, model.ApplicationChecks{Type: "http", Goal: `http://{{.IP}}:{{.HostPort "8080"}}/health/{{.HostId}}`})
+	if !result.Passed || result.Goal != "http://127.0.0.1:" + port + "/health/host1" {
+		t.Error(result)
+	}
+	result = client.runCheck(id, model.ApplicationChecks{Type: "tcp", Goal: `{{.IP}}:{{.HostPort "9090"}}`})
+	if result.Passed || result.Error == "" {
+		t.Error(result)
+	}
+}
+
+func TestDockerHealth_FoldedIntoState(t *testing.T) {
+	engine := newFakeEngine()
+	engine.health = &model.DockerHealth{Status: model.DockerHealthUnhealthy, FailingStreak: 3, Log: []model.CheckResult{{Type: "docker", Output: "down"}}}
+	client, cleanup := newTestClient(t, engine)
+	defer cleanup()
+
+	client.HandleRequestedChanges([]model.Change{{Id: "1", Type: "add_application", Name: "app1", AppConfig: model.VersionConfig{Version: "1"}}})
+	waitForChanges(t, client)
+
+	if result := client.GetChangeLog()["1"]; result.Status != model.ChangeFailed || result.Phase != model.PhaseCheck {
+		t.Error(result)
+	}
+	state := client.GetAppState()[0]
+	if state.Application.State != "checks_failed" || state.Application.DockerHealth == nil || state.Application.DockerHealth.Log[0].Output != "down" {
+		t.Error(state.Application)
+	}
+
+	engine.mutex.Lock()
+	engine.health = &model.DockerHealth{Status: model.DockerHealthHealthy}
+	engine.mutex.Unlock()
+	if state = client.GetAppState()[0]; state.Application.State != "running" {
+		t.Error(state.Application)
+	}
+}
@@ -26,9 +26,9 @@ import (
 	"math/rand"
 	"orcahostd/model"
 	"errors"
+	"path/filepath"
+	"sync"
 	"time"
-	"net/http"
-	"net"
 )
 
 var ClientLogger = Logger.LoggerWithField(Logger.Logger, "module", "client")
@@ -37,9 +37,58 @@ var cli Client
 type Client struct {
 	AppState []*model.ApplicationState
 	AppConfiguration map[string]model.VersionConfig
-	Changes map[string]bool
+	Changes map[string]model.ChangeResult
+	/* The last configuration of each app that passed its checks */
+	LastKnownGood map[string]model.VersionConfig
+	/* The images each app ran from, least recently used first */
+	Images map[string][]ImageRecord
+
+	/* Labelled containers found at startup that no application claims */
+	Orphans []model.OrphanContainer
+
+	engine docker.ContainerEngine
+	store *StateStore
+	files *FileStore
+	executor *ChangeExecutor
+	monitor *HealthMonitor
+	supervisor *Supervisor
+	portRange PortRange
+	hostId string
+	keepImages int
+	diskPressure int
+
+	/* Guards AppState, AppConfiguration, Changes, LastKnownGood and Images, changes for
+	different applications are applied in parallel. The Name and DockerAppId of an
+	AppState entry never change, everything else in an entry is written through
+	updateState and read through copies. */
+	mutex sync.Mutex
+	/* Held from picking auto host ports until they are recorded in AppState, so two
+	instances never get the same port */
+	portMutex sync.Mutex
+	/* Read locked from creating the networks of an instance until its container is attached
+	to them, write locked while collecting unused networks */
+	networkMutex sync.RWMutex
+	/* Orders writes of the persisted state, see persist */
+	saveMutex sync.Mutex
+}
 
-	engine docker.DockerContainerEngine
+type Options struct {
+	/* Directory holding the persisted client state */
+	DataDir string
+	/* Directory holding the files of the apps, DataDir/files if not set */
+	FilesDir string
+	/* Remove orphaned containers at startup instead of only reporting them */
+	RemoveOrphans bool
+	/* Number of changes applied in parallel */
+	Workers int
+	/* Host ports handed out for auto port mappings, DefaultPortRange if not set */
+	PortRange PortRange
+	/* Identifier of this host, available to check goals */
+	HostId string
+	/* Versions per app whose images survive image collection, DefaultKeepImages if not set */
+	KeepImages int
+	/* Disk usage in percent that triggers image collection, DefaultDiskPressure if not set */
+	DiskPressure int
 }
 
 type Logs struct {
@@ -47,174 +96,437 @@ type Logs struct {
 	StdErr string
 }
 
-func (client *Client) Init() {
+func (client *Client) Init(options Options) {
+	engine := &docker.DockerContainerEngine{}
+	engine.Init()
+	client.initWithEngine(options, engine)
+	go client.Supervise()
+	go client.CollectImagesOnPressure()
+}
+
+func (client *Client) initWithEngine(options Options, engine docker.ContainerEngine) {
 	ClientLogger.Info("Initializing Client...")
 	client.AppState = make([]*model.ApplicationState, 0)
-	client.Changes = make(map[string]bool)
+	client.Changes = make(map[string]model.ChangeResult)
 	client.AppConfiguration = make(map[string]model.VersionConfig)
+	client.LastKnownGood = make(map[string]model.VersionConfig)
+	client.Images = make(map[string][]ImageRecord)
+	client.Orphans = make([]model.OrphanContainer, 0)
+	client.monitor = NewHealthMonitor(client.runCheck)
+	client.supervisor = NewSupervisor()
+
+	var err error
+	client.store, err = NewStateStore(options.DataDir)
+	if err != nil {
+		ClientLogger.Fatalf("Could not open state store in %s: %s", options.DataDir, err)
+	}
+	client.load()
+
+	filesDir := options.FilesDir
+	if filesDir == "" {
+		filesDir = filepath.Join(options.DataDir, "files")
+	}
+	client.files, err = NewFileStore(filesDir)
+	if err != nil {
+		ClientLogger.Fatalf("Could not open file store in %s: %s", filesDir, err)
+	}
+
+	client.hostId = options.HostId
+	client.keepImages = options.KeepImages
+	if client.keepImages < 1 {
+		client.keepImages = DefaultKeepImages
+	}
+	client.diskPressure = options.DiskPressure
+	if client.diskPressure < 1 {
+		client.diskPressure = DefaultDiskPressure
+	}
+	client.portRange = options.PortRange
+	if client.portRange.From == 0 {
+		client.portRange = DefaultPortRange
+	}
+
+	client.engine = engine
+	client.Reconcile(options.RemoveOrphans)
+	client.collectFiles()
+	for _, state := range client.appStates() {
+		config, _ := client.configuration(state.Name)
+		client.monitor.Watch(state.DockerAppId, config, true, nil)
+	}
+	client.executor = NewChangeExecutor(options.Workers, client.applyChange)
+}
+
+func (client *Client) load() {
+	state := persistedState{}
+	if err := client.store.Load(&state); err != nil {
+		ClientLogger.Errorf("Could not load persisted state, starting empty: %s", err)
+		if err := client.store.Quarantine(); err != nil {
+			ClientLogger.Errorf("Could not move persisted state aside: %s", err)
+		}
+		return
+	}
+
+	if state.AppState != nil {
+		client.AppState = state.AppState
+	}
+	if state.AppConfiguration != nil {
+		client.AppConfiguration = state.AppConfiguration
+	}
+	if state.ChangeResults != nil {
+		client.Changes = state.ChangeResults
+	}
+	/* Changes that were queued or being applied when the host went down did not finish,
+	fail them so they are applied again when the trainer resends them */
+	for id, result := range client.Changes {
+		if result.Status == model.ChangePending || result.Status == model.ChangeApplying {
+			result.Status = model.ChangeFailed
+			result.Error = "host restarted before the change finished"
+			result.Finished = time.Now()
+			client.Changes[id] = result
+		}
+	}
+	if state.LastKnownGood != nil {
+		client.LastKnownGood = state.LastKnownGood
+	}
+	if state.Images != nil {
+		client.Images = state.Images
+	}
+	ClientLogger.Infof("Loaded %d applications and %d changes from state store", len(client.AppState), len(client.Changes))
+}
+
+/* Writes the current state to disk, must be called after every mutation */
+func (client *Client) persist() {
+	if client.store == nil {
+		return
+	}
 
-	client.engine = docker.DockerContainerEngine{}
-	client.engine.Init()
+	/* Held from taking the snapshot until it is on disk, so an older snapshot never
+	overwrites a newer one. The client mutex is only held while taking it. */
+	client.saveMutex.Lock()
+	defer client.saveMutex.Unlock()
+
+	client.mutex.Lock()
+	state := persistedState{
+		AppState: client.AppState,
+		AppConfiguration: client.AppConfiguration,
+		ChangeResults: client.Changes,
+		LastKnownGood: client.LastKnownGood,
+		Images: client.Images,
+	}
+	data, err := encodeState(&state)
+	client.mutex.Unlock()
+
+	if err == nil {
+		err = client.store.write(data)
+	}
+	if err != nil {
+		ClientLogger.Errorf("Could not persist state: %s", err)
+	}
 }
 
-func (client *Client) HandleRequestedChanges(changes []model.Change) bool {
+/* Hands the changes we have not dealt with yet to the executor, it does not wait for them */
+func (client *Client) HandleRequestedChanges(changes []model.Change) {
 	for _, change := range changes {
 		/* First check that we have not already dealth with this change */
-		if _, ok := client.Changes[change.Id]; ok {
+		if !client.admitChange(change.Id) {
 			continue
 		}
 
-		if change.Type == "add_application" {
+		if client.executor.Submit(change) {
+			ClientLogger.Infof("Queued change %s (%s) for app %s", change.Id, change.Type, change.Name)
+		} else {
+			client.rejectChange(change.Id)
+		}
+	}
+	client.persist()
+}
+
+func (client *Client) applyChange(change model.Change) {
+	client.startChange(change.Id)
+
+	var err error
+	if change.Type == "add_application" {
+		/* An invalid config must not take down the running app */
+		if validateErr := client.validateConfig(change.AppConfig); validateErr != nil {
+			ClientLogger.Errorf("Invalid config for app %s:%s, keeping the running app: %s", change.Name, change.AppConfig.Version, validateErr)
+			err = phaseError(model.PhaseCreate, validateErr)
+		} else {
 			/* First things first, check that we do not already have this application. If we do, nuke it */
-			_, err := client.GetAppStateIndividual(change.Name)
-			if err == nil {
+			_, stateErr := client.GetAppStateIndividual(change.Name)
+			if stateErr == nil {
 				client.DeleteApp(change.Name)
 			}
 
-			if client.DeployApp(change.Name, change.AppConfig) {
-				client.Changes[change.Id] = true
-				return true
-			}
+			err = client.DeployApp(change.Name, change.Id, change.AppConfig)
 		}
-
-		if change.Type == "remove_application" {
-			if client.DeleteApp(change.Name) {
-				client.Changes[change.Id] = true
-			}
+	} else if change.Type == "update_application" {
+		err = client.UpdateApp(change.Name, change.Id, change.AppConfig)
+	} else if change.Type == "remove_application" {
+		client.DeleteApp(change.Name)
+		client.deleteLastKnownGood(change.Name)
+		if change.Purge {
+			err = client.PurgeVolumes(change.Name)
 		}
+	} else if change.Type == "restart_application" {
+		err = client.RestartApp(change.Name)
+	} else if change.Type == "stop_application" {
+		err = client.StopApp(change.Name)
+	} else if change.Type == "start_application" {
+		err = client.StartApp(change.Name)
+	} else if change.Type == "signal_application" {
+		err = client.SignalApp(change.Name, change.Signal)
+	} else if change.Type == "prepull_image" {
+		err = client.PrepullImage(change.Name, change.AppConfig)
+	} else {
+		err = fmt.Errorf("Unknown change type %s", change.Type)
 	}
 
-	return false
+	client.finishChange(change, err)
+}
+
+/* Receives a value whenever the executor finished changes */
+func (client *Client) ChangesDone() <-chan struct{} {
+	return client.executor.Done()
 }
 
 func GenerateId(app string) string {
 	return string(fmt.Sprintf("%s_%d", app, rand.Int31()))
 }
 
-func (client *Client) RunCheck(config model.VersionConfig) bool {
-	for _, change := range config.Checks {
-		if change.Type == "http" {
-			res, err := http.Get(change.Goal)
-			if err != nil {
-				return false
-			}
+func (client *Client) DeployApp(name string, changeId string, config model.VersionConfig) error {
+	if err := client.validateConfig(config); err != nil {
+		return phaseError(model.PhaseCreate, err)
+	}
 
-			defer res.Body.Close()
-			if res.StatusCode != 200 {
-				return false
-			}
+	ClientLogger.Infof("Installing app %s:%s", name, config.Version)
+	/* A failed pull is not fatal yet, the image may already be on the host */
+	pullErr := client.engine.InstallApp(name, config)
 
-		}else if change.Type == "tcp"{
-			socket, err := net.Dial("tcp", change.Goal)
-			if err != nil {
-				return false
-			}
-			socket.Close()
-		}
+	/* Add the configuration for this application */
+	client.setConfiguration(name, config)
+	newAppStates, changeErr := client.startAndCheck(name, changeId, config, instanceRange(0, replicas(config)), pullErr)
+	if changeErr == nil {
+		client.setLastKnownGood(name, config)
+	} else if config.AutoRollback {
+		changeErr = client.rollback(name, changeId, config, newAppStates, changeErr)
 	}
+	client.persist()
 
-	return true
+	ClientLogger.Infof("Starting app %s:%s done. Success=%t", name, config.Version, changeErr == nil)
+	/* Do not return a nil *ChangeError as a non nil error */
+	if changeErr != nil {
+		return changeErr
+	}
+	return nil
 }
 
-func (client *Client) DeployApp(name string, config model.VersionConfig) bool {
-	ClientLogger.Infof("Installing app %s:%d", name, config.Version)
-	client.engine.InstallApp(name, config)
-
-	ClientLogger.Infof("Starting app %s:%d", name, config.Version)
+/* Creates and starts a new container for the app and records it in AppState */
+func (client *Client) startInstance(name string, changeId string, config model.VersionConfig, instance int) (*model.ApplicationState, *ChangeError) {
+	ClientLogger.Infof("Starting app %s:%s instance %d", name, config.Version, instance)
 	id := GenerateId(name)
 	newAppState := &model.ApplicationState{
 		Name: name,
 		DockerAppId: id,
+		Instance: instance,
 		Application: model.Application{
 			State:"",
-			ChangeId:"",
+			ChangeId:changeId,
 			Name:name,
 			Version: config.Version,
 		},
 	}
 
-	client.AppState = append(client.AppState, newAppState)
-	/* Add the configuration for this application */
-	client.AppConfiguration[name] = config
-	res := client.engine.RunApp(id, name, config)
-	if !res {
-		newAppState.Application.State = "installation_failed"
-	}else{
-		for i := 1; i <= 10; i++ {
-			if !client.RunCheck(config) {
-				if i == 10 {
-					newAppState.Application.State = "checks_failed"
-					break
-				}
-
-				time.Sleep(time.Duration(6 * time.Second))
-				continue
-			} else {
-				newAppState.Application.State = "running"
-				break
+	client.portMutex.Lock()
+	ports, portErr := client.resolvePorts(config)
+	newAppState.Ports = ports
+	client.addAppState(newAppState)
+	client.portMutex.Unlock()
+
+	/* Persist before creating the container so a crash never leaves one we do not know about */
+	client.persist()
+	if portErr != nil {
+		ClientLogger.Errorf("Could not allocate host ports for app %s: %s", name, portErr)
+		client.setState(newAppState, "installation_failed")
+		return newAppState, phaseError(model.PhaseCreate, portErr)
+	}
+
+	/* The labels carry the hash of the requested config, the container gets the resolved ports
+	and the mounts of the files written for it */
+	resolved := config
+	resolved.PortMappings = ports
+	if err := client.ensureVolumes(name, config); err != nil {
+		client.setState(newAppState, "installation_failed")
+		return newAppState, phaseError(model.PhaseCreate, err)
+	}
+	fileMounts, err := client.files.Write(name, id, config.Files)
+	if err != nil {
+		ClientLogger.Errorf("Could not write the files of app %s: %s", name, err)
+		client.setState(newAppState, "installation_failed")
+		return newAppState, phaseError(model.PhaseCreate, err)
+	}
+	resolved.Files = nil
+	resolved.VolumeMappings = append(append([]model.VolumeMapping{}, config.VolumeMappings...), fileMounts...)
+	if err := client.createOnNetworks(id, name, resolved, ContainerLabels(name, changeId, config, instance)); err != nil {
+		client.setState(newAppState, "installation_failed")
+		return newAppState, phaseError(model.PhaseCreate, err)
+	}
+	if err := client.engine.StartApp(id); err != nil {
+		client.setState(newAppState, "installation_failed")
+		return newAppState, phaseError(model.PhaseStart, err)
+	}
+	client.recordContainer(newAppState)
+	client.recordImage(name, config, client.copyState(newAppState).ImageId)
+	return newAppState, nil
+}
+
+/* Records the host ports docker actually bound and the image the instance runs */
+func (client *Client) recordContainer(state *model.ApplicationState) {
+	info, err := client.engine.InspectApp(state.DockerAppId)
+	if err != nil {
+		return
+	}
+	client.updateState(state, func(state *model.ApplicationState) {
+		state.Ports = info.Ports
+		state.ImageId = info.ImageId
+		state.Digest = info.Digest
+		state.Resources = info.Resources
+	})
+}
+
+/* Starts the given instances of the app, then waits for their checks. It stops at the
+first failure and returns the instances started so far. A failed create after a failed
+pull is reported as a pull failure, that is what the user has to fix. */
+func (client *Client) startAndCheck(name string, changeId string, config model.VersionConfig, instances []int, pullErr error) ([]*model.ApplicationState, *ChangeError) {
+	newAppStates := make([]*model.ApplicationState, 0)
+	for _, instance := range instances {
+		newAppState, changeErr := client.startInstance(name, changeId, config, instance)
+		newAppStates = append(newAppStates, newAppState)
+		if changeErr != nil {
+			if changeErr.Phase == model.PhaseCreate && pullErr != nil {
+				changeErr = phaseError(model.PhasePull, pullErr)
 			}
+			return newAppStates, changeErr
 		}
 	}
 
-	ClientLogger.Infof("Starting app %s:%d done. Success=%t", name, config.Version, res)
-	return res
+	for _, newAppState := range newAppStates {
+		if !client.awaitChecks(newAppState, config) {
+			return newAppStates, phaseError(model.PhaseCheck, errors.New("Checks did not pass"))
+		}
+	}
+	return newAppStates, nil
 }
 
+/* Removes the containers of the given instances and forgets about them */
+func (client *Client) removeInstances(states []*model.ApplicationState) {
+	for _, state := range states {
+		client.engine.RemoveApp(state.DockerAppId)
+		client.monitor.Unwatch(state.DockerAppId)
+		client.files.RemoveInstance(state.Name, state.DockerAppId)
+		client.delAppStateByDockerId(state.DockerAppId)
+	}
+	client.persist()
+	client.CollectNetworks()
+}
 
 func (client *Client) DeleteApp(name string) bool {
 	ClientLogger.Infof("Starting deletion of app %s", name)
-	app, err := client.GetAppStateIndividual(name)
+	states, err := client.instances(name)
 	if err == nil {
-		client.engine.StopApp(app.DockerAppId)
+		for _, state := range states {
+			client.engine.RemoveApp(state.DockerAppId)
+			client.monitor.Unwatch(state.DockerAppId)
+		}
+		client.files.RemoveApp(name)
 		client.DelAppStateIndividual(name)
+		client.deleteConfiguration(name)
+		client.persist()
+		client.CollectNetworks()
 	}
+	/* Also for apps that were only pre-pulled */
+	client.engine.ForgetPull(name)
 
 	return true;
 }
 
+/* Metrics of every instance, keyed by DockerAppId */
 func (client *Client) GetAppMetrics() map[string]model.Metric {
 	ret := make(map[string]model.Metric)
-	for _, application := range client.AppState {
+	for _, application := range client.appStates() {
 		metric, _ := client.engine.AppMetrics(application.DockerAppId)
-		ret[application.Name] = metric
+		ret[application.DockerAppId] = metric
 	}
 	return ret
 }
 
+/* Logs keyed by application name, the logs of all instances of an app are joined */
 func (client *Client) GetAppLogs() map[string]Logs {
 	ret := make(map[string]Logs)
-	for _, application := range client.AppState {
+	for _, application := range client.appStates() {
 		out, err := client.engine.AppLogs(application.DockerAppId)
-		ret[application.Name] = Logs{ StdOut: out, StdErr:err}
+		logs := ret[application.Name]
+		ret[application.Name] = Logs{ StdOut: logs.StdOut + out, StdErr: logs.StdErr + err}
 	}
 	return ret
 }
 
+/* Progress of the latest image pull of each app */
+func (client *Client) GetPullProgress() map[string]model.PullProgress {
+	return client.engine.PullProgress()
+}
+
+/* Pulls the image of a version ahead of deploying it, nothing is started */
+func (client *Client) PrepullImage(name string, config model.VersionConfig) error {
+	ClientLogger.Infof("Pre-pulling image %s for app %s", docker.ImageName(config.DockerConfig), name)
+	if err := client.engine.InstallApp(name, config); err != nil {
+		return phaseError(model.PhasePull, err)
+	}
+	return nil
+}
+
 func (client *Client) GetHostMetrics() model.Metric{
 	return client.engine.HostMetrics()
 }
 
+/* Returns copies of the application states, refreshed from the engine and checks */
 func (client *Client) GetAppState() []*model.ApplicationState{
 	// We need to update the AppState before returning it:
-	for _, state := range client.AppState {
-		if client.engine.QueryApp(state.DockerAppId) {
-			appConfiguration := client.AppConfiguration[state.Name]
-
-			if !client.RunCheck(appConfiguration) {
-				state.Application.State = "checks_failed"
-			}else{
-				state.Application.State = "running"
+	ret := make([]*model.ApplicationState, 0)
+	for _, state := range client.appStates() {
+		current := client.copyState(state)
+		info, err := client.engine.InspectApp(state.DockerAppId)
+		if current.Stopped {
+			client.setState(state, "stopped")
+		}else if current.CrashLoop {
+			client.setState(state, "crash_loop")
+		}else if err == nil && info.Running {
+			healthy, watched := client.monitor.Health(state.DockerAppId)
+			switch {
+			case info.Health != nil && info.Health.Status == model.DockerHealthUnhealthy:
+				client.setState(state, "checks_failed")
+			/* Instances the monitor does not watch yet are still being deployed */
+			case !watched || (info.Health != nil && info.Health.Status == model.DockerHealthStarting):
+			case !healthy:
+				client.setState(state, "checks_failed")
+			default:
+				client.setState(state, "running")
 			}
 		}else{
-			state.Application.State = "failed"
+			client.setState(state, "failed")
 		}
+
+		copied := client.copyState(state)
+		copied.Ready = copied.Application.State == "running" && client.monitor.Ready(state.DockerAppId)
+		copied.Application.CheckResults = client.monitor.Results(state.DockerAppId)
+		copied.Application.DockerHealth = info.Health
+		ret = append(ret, &copied)
 	}
 
-	return client.AppState
+	return ret
 }
 
 func (client *Client) GetAppStateIndividual(application string) (*model.ApplicationState, error){
-	// We need to update the AppState before returning it:
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
 	for _, state := range client.AppState {
 		if state.Name == application {
 			return state, nil
@@ -225,7 +537,8 @@ func (client *Client) GetAppStateIndividual(application string) (*model.Applicat
 }
 
 func (client *Client) DelAppStateIndividual(application string){
-	// We need to update the AppState before returning it:
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
 	states := make([]*model.ApplicationState, 0)
 
 	for _, state := range client.AppState {
@@ -237,6 +550,87 @@ func (client *Client) DelAppStateIndividual(application string){
 	client.AppState = states
 }
 
-func (client *Client) GetChangeLog() map[string]bool {
-	return client.Changes
+func (client *Client) delAppStateByDockerId(dockerAppId string) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	states := make([]*model.ApplicationState, 0)
+
+	for _, state := range client.AppState {
+		if state.DockerAppId != dockerAppId {
+			states = append(states, state)
+		}
+	}
+
+	client.AppState = states
+}
+
+/* All writes to an AppState entry go through here */
+func (client *Client) updateState(state *model.ApplicationState, update func(state *model.ApplicationState)) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	update(state)
+}
+
+func (client *Client) setState(state *model.ApplicationState, value string) {
+	client.updateState(state, func(state *model.ApplicationState) {
+		state.Application.State = value
+	})
+}
+
+/* Returns a copy of the entry that is safe to read while changes are applied */
+func (client *Client) copyState(state *model.ApplicationState) model.ApplicationState {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	return *state
+}
+
+func (client *Client) addAppState(state *model.ApplicationState) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.AppState = append(client.AppState, state)
+}
+
+/* Returns a copy of AppState that is safe to range over while changes are applied */
+func (client *Client) appStates() []*model.ApplicationState {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	return append([]*model.ApplicationState{}, client.AppState...)
+}
+
+func (client *Client) configuration(name string) (model.VersionConfig, bool) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	config, ok := client.AppConfiguration[name]
+	return config, ok
+}
+
+func (client *Client) setConfiguration(name string, config model.VersionConfig) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.AppConfiguration[name] = config
+}
+
+func (client *Client) deleteConfiguration(name string) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	delete(client.AppConfiguration, name)
+}
+
+func (client *Client) lastKnownGood(name string) (model.VersionConfig, bool) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	config, ok := client.LastKnownGood[name]
+	return config, ok
+}
+
+func (client *Client) setLastKnownGood(name string, config model.VersionConfig) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.LastKnownGood[name] = config
+}
+
+func (client *Client) deleteLastKnownGood(name string) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	delete(client.LastKnownGood, name)
 }
\ No newline at end of file
@@ -0,0 +1,145 @@
+package client
+
+import (
+	"fmt"
+	"orcahostd/model"
+	"sync"
+	"testing"
+)
+
+/* Run with -race, deploys and removals happen while logs, metrics and state are read */
+func TestClient_ConcurrentDeploysLogsAndMetrics(t *testing.T) {
+	engine := newFakeEngine()
+	client, cleanup := newTestClient(t, engine)
+	defer cleanup()
+
+	stop := make(chan struct{})
+	var readers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				client.GetAppLogs()
+				client.GetAppMetrics()
+				for _, state := range client.GetAppState() {
+					state.Application.Metrics = model.Metric{}
+				}
+				client.GetChangeLog()
+			}
+		}()
+	}
+
+	changes := make([]model.Change, 0)
+	for i := 0; i < 8; i++ {
+		name := fmt.Sprintf("app%d", i)
+		changes = append(changes, model.Change{Id: name + "_add", Type: "add_application", Name: name, AppConfig: model.VersionConfig{Version: "1"}})
+		changes = append(changes, model.Change{Id: name + "_update", Type: "update_application", Name: name, AppConfig: model.VersionConfig{Version: "2"}})
+	}
+	changes = append(changes, model.Change{Id: "app0_remove", Type: "remove_application", Name: "app0"})
+	client.HandleRequestedChanges(changes)
+	waitForChanges(t, client)
+
+	close(stop)
+	readers.Wait()
+
+	states := client.GetAppState()
+	if len(states) != 7 || engine.count() != 7 {
+		t.Fatalf("expected 7 apps, got %d states and %d containers", len(states), engine.count())
+	}
+	for _, state := range states {
+		if state.Application.Version != "2" || state.Application.State != "running" {
+			t.Error(state)
+		}
+	}
+	for id, result := range client.GetChangeLog() {
+		if result.Status != model.ChangeSucceeded || result.Attempts != 1 {
+			t.Error(id, result)
+		}
+	}
+	if len(client.GetChangeLog()) != len(changes) {
+		t.Error(client.GetChangeLog())
+	}
+}
+
+func TestClient_StatePersistedAcrossRestart(t *testing.T) {
+	engine := newFakeEngine()
+	client, cleanup := newTestClient(t, engine)
+	defer cleanup()
+
+	client.HandleRequestedChanges([]model.Change{{Id: "1", Type: "add_application", Name: "app1", AppConfig: model.VersionConfig{Version: "1"}}})
+	waitForChanges(t, client)
+
+	restarted := &Client{}
+	restarted.initWithEngine(Options{DataDir: client.store.dataDir}, engine)
+	if _, err := restarted.GetAppStateIndividual("app1"); err != nil {
+		t.Error(err)
+	}
+	if restarted.admitChange("1") {
+		t.Error("change was forgotten")
+	}
+	if len(restarted.GetOrphans()) != 0 {
+		t.Error(restarted.GetOrphans())
+	}
+}
+
+func TestClient_UnfinishedChangeRetriedAfterRestart(t *testing.T) {
+	engine := newFakeEngine()
+	client, cleanup := newTestClient(t, engine)
+	defer cleanup()
+
+	/* The host went down after admitting the change, before applying it */
+	if !client.admitChange("1") {
+		t.Fatal("change was not admitted")
+	}
+	client.persist()
+
+	restarted := &Client{}
+	restarted.initWithEngine(Options{DataDir: client.store.dataDir, Workers: 1}, engine)
+	if result := restarted.GetChangeLog()["1"]; result.Status != model.ChangeFailed {
+		t.Error(result)
+	}
+
+	restarted.HandleRequestedChanges([]model.Change{{Id: "1", Type: "add_application", Name: "app1", AppConfig: model.VersionConfig{Version: "1"}}})
+	waitForChanges(t, restarted)
+	if result := restarted.GetChangeLog()["1"]; result.Status != model.ChangeSucceeded || result.Attempts != 1 {
+		t.Error(result)
+	}
+	if _, err := restarted.GetAppStateIndividual("app1"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClient_ResentWhileExecutorFinishes_StaysRetryable(t *testing.T) {
+	engine := newFakeEngine()
+	client, cleanup := newTestClient(t, engine)
+	defer cleanup()
+
+	/* The change finished as failed, the executor has not dropped it yet */
+	client.mutex.Lock()
+	client.Changes["1"] = model.ChangeResult{Status: model.ChangeFailed, Attempts: 1}
+	client.mutex.Unlock()
+	client.executor.mutex.Lock()
+	client.executor.inProgress["1"] = model.ChangeApplying
+	client.executor.mutex.Unlock()
+
+	change := model.Change{Id: "1", Type: "add_application", Name: "app1", AppConfig: model.VersionConfig{Version: "1"}}
+	client.HandleRequestedChanges([]model.Change{change})
+	if result := client.GetChangeLog()["1"]; result.Status != model.ChangeFailed {
+		t.Fatal(result)
+	}
+
+	client.executor.mutex.Lock()
+	delete(client.executor.inProgress, "1")
+	client.executor.mutex.Unlock()
+	client.HandleRequestedChanges([]model.Change{change})
+	waitForChanges(t, client)
+	if result := client.GetChangeLog()["1"]; result.Status != model.ChangeSucceeded || result.Attempts != 2 {
+		t.Error(result)
+	}
+}
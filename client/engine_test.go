@@ -0,0 +1,360 @@
+package client
+
+import (
+	"errors"
+	"io/ioutil"
+	"orcahostd/docker"
+	"orcahostd/model"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+/* fakeEngine keeps containers in memory so the client can be tested without docker */
+type fakeEngine struct {
+	mutex      sync.Mutex
+	containers map[string]*docker.ManagedContainer
+	failCreate map[string]bool
+	ports      map[string][]model.PortMapping
+	usedPorts  map[int]bool
+	images     map[string]model.DockerConfig
+	/* Images removed with RemoveImage */
+	removedImages []string
+	pulls      map[string]model.PullProgress
+	resources  map[string]model.Resources
+	/* Exit code of every command run with ExecApp */
+	execExitCode int
+	/* HEALTHCHECK state reported for every container */
+	health *model.DockerHealth
+	/* Networks that exist and the networks each container is attached to */
+	networks   map[string]bool
+	attached   map[string][]model.NetworkAttachment
+	failNetwork bool
+	/* Called at the start of every CreateApp */
+	beforeCreate func()
+	/* Named volumes keyed by docker name, and the volume mappings of each container */
+	volumes    map[string]model.NamedVolume
+	mounts     map[string][]model.VolumeMapping
+}
+
+func newFakeEngine() *fakeEngine {
+	return &fakeEngine{
+		containers: make(map[string]*docker.ManagedContainer),
+		failCreate: make(map[string]bool),
+		ports: make(map[string][]model.PortMapping),
+		usedPorts: make(map[int]bool),
+		images: make(map[string]model.DockerConfig),
+		pulls: make(map[string]model.PullProgress),
+		resources: make(map[string]model.Resources),
+		networks: make(map[string]bool),
+		attached: make(map[string][]model.NetworkAttachment),
+		volumes: make(map[string]model.NamedVolume),
+		mounts: make(map[string][]model.VolumeMapping),
+	}
+}
+
+func (engine *fakeEngine) InstallApp(name string, config model.VersionConfig) error {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+	engine.pulls[name] = model.PullProgress{Image: docker.ImageName(config.DockerConfig), Status: model.PullDone}
+	return nil
+}
+
+func (engine *fakeEngine) ForgetPull(name string) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+	delete(engine.pulls, name)
+}
+
+func (engine *fakeEngine) PullProgress() map[string]model.PullProgress {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+	ret := make(map[string]model.PullProgress)
+	for name, progress := range engine.pulls {
+		ret[name] = progress
+	}
+	return ret
+}
+
+func (engine *fakeEngine) CreateApp(appId string, name string, appConf model.VersionConfig, labels map[string]string) error {
+	if engine.beforeCreate != nil {
+		engine.beforeCreate()
+	}
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+	if engine.failCreate[appConf.Version] {
+		return errors.New("create failed")
+	}
+	engine.containers[appId] = &docker.ManagedContainer{DockerAppId: appId, Labels: labels}
+	engine.ports[appId] = appConf.PortMappings
+	engine.images[appId] = appConf.DockerConfig
+	engine.resources[appId] = appConf.Resources
+	for _, network := range appConf.Networks {
+		if !engine.networks[network.Name] {
+			return errors.New("no such network " + network.Name)
+		}
+	}
+	engine.attached[appId] = appConf.Networks
+	for _, mapping := range appConf.VolumeMappings {
+		if mapping.Volume != "" {
+			if _, ok := engine.volumes[docker.VolumeName(name, mapping.Volume)]; !ok {
+				return errors.New("no such volume " + mapping.Volume)
+			}
+		}
+	}
+	engine.mounts[appId] = appConf.VolumeMappings
+	return nil
+}
+
+func (engine *fakeEngine) ListApps() ([]docker.ManagedContainer, error) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+	ret := make([]docker.ManagedContainer, 0)
+	for _, container := range engine.containers {
+		ret = append(ret, *container)
+	}
+	return ret, nil
+}
+
+func (engine *fakeEngine) QueryApp(appId string) bool {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+	container, ok := engine.containers[appId]
+	return ok && container.Running
+}
+
+func (engine *fakeEngine) InspectApp(appId string) (docker.AppInfo, error) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+	container, ok := engine.containers[appId]
+	if !ok {
+		return docker.AppInfo{}, errors.New("no such container")
+	}
+	image := engine.images[appId]
+	info := docker.AppInfo{
+		Running: container.Running,
+		IP: "127.0.0.1",
+		Ports: engine.ports[appId],
+		Health: engine.health,
+		ImageId: "image-" + docker.ImageName(image),
+		Digest: image.Reference,
+		Resources: engine.resources[appId],
+	}
+	if !container.Running {
+		info.ExitCode = 1
+	}
+	return info, nil
+}
+
+func (engine *fakeEngine) UsedHostPorts() (map[int]bool, error) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+	used := make(map[int]bool)
+	for port := range engine.usedPorts {
+		used[port] = true
+	}
+	return used, nil
+}
+
+func (engine *fakeEngine) ImagesInUse() (map[string]bool, error) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+	used := make(map[string]bool)
+	for appId := range engine.containers {
+		used[docker.ImageName(engine.images[appId])] = true
+	}
+	return used, nil
+}
+
+func (engine *fakeEngine) RemoveImage(image string) error {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+	engine.removedImages = append(engine.removedImages, image)
+	return nil
+}
+
+func (engine *fakeEngine) setRunning(appId string, running bool) bool {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+	container, ok := engine.containers[appId]
+	if ok {
+		container.Running = running
+	}
+	return ok
+}
+
+func (engine *fakeEngine) StopApp(appId string) error {
+	if !engine.setRunning(appId, false) {
+		return errors.New("no such container")
+	}
+	return nil
+}
+
+func (engine *fakeEngine) RestartApp(appId string) error {
+	return engine.StartApp(appId)
+}
+
+func (engine *fakeEngine) SignalApp(appId string, signal string) error {
+	if !engine.QueryApp(appId) {
+		return errors.New("container is not running")
+	}
+	return nil
+}
+
+func (engine *fakeEngine) ExecApp(appId string, command []string, timeout time.Duration) (docker.ExecResult, error) {
+	if !engine.QueryApp(appId) {
+		return docker.ExecResult{}, errors.New("container is not running")
+	}
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+	return docker.ExecResult{ExitCode: engine.execExitCode, Output: strings.Join(command, " ")}, nil
+}
+
+func (engine *fakeEngine) StartApp(appId string) error {
+	engine.mutex.Lock()
+	for _, network := range engine.attached[appId] {
+		if !engine.networks[network.Name] {
+			engine.mutex.Unlock()
+			return errors.New("network " + network.Name + " not found")
+		}
+	}
+	engine.mutex.Unlock()
+	if !engine.setRunning(appId, true) {
+		return errors.New("no such container")
+	}
+	return nil
+}
+
+func (engine *fakeEngine) RemoveApp(appId string) bool {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+	_, ok := engine.containers[appId]
+	delete(engine.containers, appId)
+	delete(engine.ports, appId)
+	delete(engine.attached, appId)
+	delete(engine.mounts, appId)
+	return ok
+}
+
+func (engine *fakeEngine) EnsureVolume(app string, volume model.NamedVolume) error {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+	name := docker.VolumeName(app, volume.Name)
+	if existing, ok := engine.volumes[name]; ok {
+		if existing.Labels[docker.LabelApp] != app || existing.Labels[docker.LabelVolume] != volume.Name {
+			return errors.New("volume belongs to another app")
+		}
+		return nil
+	}
+	volume.Labels = map[string]string{docker.LabelApp: app, docker.LabelVolume: volume.Name}
+	engine.volumes[name] = volume
+	return nil
+}
+
+func (engine *fakeEngine) RemoveVolumes(app string) ([]string, error) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+	removed := make([]string, 0)
+	for name, volume := range engine.volumes {
+		if volume.Labels[docker.LabelApp] == app {
+			delete(engine.volumes, name)
+			removed = append(removed, name)
+		}
+	}
+	return removed, nil
+}
+
+func (engine *fakeEngine) VolumeUsage(app string) ([]model.VolumeUsage, error) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+	ret := make([]model.VolumeUsage, 0)
+	for name, volume := range engine.volumes {
+		if volume.Labels[docker.LabelApp] != app {
+			continue
+		}
+		usage := model.VolumeUsage{Name: volume.Name, DockerName: name, Driver: "local", Size: 4096}
+		for appId, mappings := range engine.mounts {
+			if engine.containers[appId].Labels[docker.LabelApp] != app {
+				continue
+			}
+			for _, mapping := range mappings {
+				if mapping.Volume == volume.Name {
+					usage.Containers++
+				}
+			}
+		}
+		ret = append(ret, usage)
+	}
+	return ret, nil
+}
+
+func (engine *fakeEngine) EnsureNetwork(name string) error {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+	if engine.failNetwork {
+		return errors.New("network create failed")
+	}
+	engine.networks[name] = true
+	return nil
+}
+
+func (engine *fakeEngine) RemoveUnusedNetworks() ([]string, error) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+	used := make(map[string]bool)
+	for _, networks := range engine.attached {
+		for _, network := range networks {
+			used[network.Name] = true
+		}
+	}
+	removed := make([]string, 0)
+	for name := range engine.networks {
+		if !used[name] {
+			delete(engine.networks, name)
+			removed = append(removed, name)
+		}
+	}
+	return removed, nil
+}
+
+func (engine *fakeEngine) HostMetrics() model.Metric {
+	return model.Metric{CpuUsage: 1}
+}
+
+func (engine *fakeEngine) HostCapacity() (model.HostCapacity, error) {
+	return model.HostCapacity{Memory: 1 << 30, Cpus: 2}, nil
+}
+
+func (engine *fakeEngine) AppMetrics(appId string) (model.Metric, error) {
+	if !engine.QueryApp(appId) {
+		return model.Metric{}, errors.New("no such container")
+	}
+	return model.Metric{CpuUsage: 1, MemoryUsage: 1}, nil
+}
+
+func (engine *fakeEngine) AppLogs(appId string) (string, string) {
+	return "out " + appId, ""
+}
+
+func (engine *fakeEngine) count() int {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+	return len(engine.containers)
+}
+
+func newTestClient(t *testing.T, engine docker.ContainerEngine) (*Client, func()) {
+	dir, err := ioutil.TempDir("", "orcahostd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &Client{}
+	client.initWithEngine(Options{DataDir: dir, Workers: 4}, engine)
+	return client, func() { os.RemoveAll(dir) }
+}
+
+/* Waits until the executor has nothing left to do */
+func waitForChanges(t *testing.T, client *Client) {
+	waitForExecutor(t, client.executor)
+}
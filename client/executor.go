@@ -0,0 +1,136 @@
+/*
+Copyright Alex Mack and Michael Lawson (michael@sphinix.com)
+This file is part of Orca.
+
+Orca is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Orca is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Orca.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package client
+
+import (
+	"orcahostd/model"
+	"sync"
+)
+
+/* ChangeExecutor applies changes in the background. Changes for one application are
+queued and applied in the order they arrived, changes for different applications run
+in parallel on at most `workers` goroutines. */
+type ChangeExecutor struct {
+	apply   func(model.Change)
+	workers chan struct{}
+	done    chan struct{}
+
+	mutex      sync.Mutex
+	queues     map[string][]model.Change
+	inProgress map[string]string
+}
+
+func NewChangeExecutor(workers int, apply func(model.Change)) *ChangeExecutor {
+	if workers < 1 {
+		workers = 1
+	}
+	return &ChangeExecutor{
+		apply: apply,
+		workers: make(chan struct{}, workers),
+		done: make(chan struct{}, 1),
+		queues: make(map[string][]model.Change),
+		inProgress: make(map[string]string),
+	}
+}
+
+/* Queues a change. Returns false if the change is already queued or being applied. */
+func (executor *ChangeExecutor) Submit(change model.Change) bool {
+	executor.mutex.Lock()
+	defer executor.mutex.Unlock()
+
+	if _, ok := executor.inProgress[change.Id]; ok {
+		return false
+	}
+
+	executor.inProgress[change.Id] = model.ChangePending
+	queue := executor.queues[change.Name]
+	executor.queues[change.Name] = append(queue, change)
+
+	/* An application with a non empty queue already has a goroutine draining it */
+	if len(queue) == 0 {
+		go executor.drain(change.Name)
+	}
+	return true
+}
+
+func (executor *ChangeExecutor) drain(app string) {
+	for {
+		executor.mutex.Lock()
+		change := executor.queues[app][0]
+		executor.mutex.Unlock()
+
+		executor.workers <- struct{}{}
+		executor.setState(change.Id, model.ChangeApplying)
+		executor.apply(change)
+		<-executor.workers
+
+		/* The change stays at the head of the queue until it is done, so Submit never
+		starts a second goroutine for the same application */
+		executor.mutex.Lock()
+		delete(executor.inProgress, change.Id)
+		remaining := executor.queues[app][1:]
+		if len(remaining) == 0 {
+			delete(executor.queues, app)
+		} else {
+			executor.queues[app] = remaining
+		}
+		executor.mutex.Unlock()
+
+		/* Wake up whoever waits for changes without blocking if nobody does */
+		select {
+		case executor.done <- struct{}{}:
+		default:
+		}
+
+		if len(remaining) == 0 {
+			return
+		}
+	}
+}
+
+func (executor *ChangeExecutor) setState(id string, state string) {
+	executor.mutex.Lock()
+	defer executor.mutex.Unlock()
+	executor.inProgress[id] = state
+}
+
+/* The changes that are queued or being applied, keyed by change id */
+func (executor *ChangeExecutor) InProgress() map[string]string {
+	executor.mutex.Lock()
+	defer executor.mutex.Unlock()
+
+	ret := make(map[string]string)
+	for id, state := range executor.inProgress {
+		ret[id] = state
+	}
+	return ret
+}
+
+/* Whether changes for the application are queued or being applied */
+func (executor *ChangeExecutor) Busy(app string) bool {
+	executor.mutex.Lock()
+	defer executor.mutex.Unlock()
+	return len(executor.queues[app]) > 0
+}
+
+/* Receives a value after one or more changes finished */
+func (executor *ChangeExecutor) Done() <-chan struct{} {
+	return executor.done
+}
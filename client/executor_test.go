@@ -0,0 +1,96 @@
+package client
+
+import (
+	"orcahostd/model"
+	"sync"
+	"testing"
+	"time"
+)
+
+func waitForExecutor(t *testing.T, executor *ChangeExecutor) {
+	deadline := time.Now().Add(5 * time.Second)
+	for len(executor.InProgress()) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("executor did not finish", executor.InProgress())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestChangeExecutor_SameApp_AppliedInOrder(t *testing.T) {
+	var mutex sync.Mutex
+	applied := make([]string, 0)
+	executor := NewChangeExecutor(4, func(change model.Change) {
+		time.Sleep(10 * time.Millisecond)
+		mutex.Lock()
+		applied = append(applied, change.Id)
+		mutex.Unlock()
+	})
+
+	for _, id := range []string{"1", "2", "3"} {
+		executor.Submit(model.Change{Id: id, Name: "app1"})
+	}
+	waitForExecutor(t, executor)
+
+	if len(applied) != 3 || applied[0] != "1" || applied[1] != "2" || applied[2] != "3" {
+		t.Error(applied)
+	}
+}
+
+func TestChangeExecutor_DuplicateSubmit_Ignored(t *testing.T) {
+	release := make(chan struct{})
+	count := 0
+	executor := NewChangeExecutor(1, func(change model.Change) {
+		<-release
+		count++
+	})
+
+	if !executor.Submit(model.Change{Id: "1", Name: "app1"}) {
+		t.Error("first submit should be accepted")
+	}
+	if executor.Submit(model.Change{Id: "1", Name: "app1"}) {
+		t.Error("second submit should be ignored")
+	}
+	close(release)
+	waitForExecutor(t, executor)
+
+	if count != 1 {
+		t.Error(count)
+	}
+}
+
+func TestChangeExecutor_DifferentApps_RunInParallelUpToWorkers(t *testing.T) {
+	var mutex sync.Mutex
+	running, maxRunning := 0, 0
+	executor := NewChangeExecutor(2, func(change model.Change) {
+		mutex.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mutex.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mutex.Lock()
+		running--
+		mutex.Unlock()
+	})
+
+	for _, app := range []string{"app1", "app2", "app3", "app4"} {
+		executor.Submit(model.Change{Id: app, Name: app})
+	}
+	if executor.InProgress()["app1"] == "" {
+		t.Error("submitted change should be reported in progress")
+	}
+	waitForExecutor(t, executor)
+
+	if maxRunning != 2 {
+		t.Error(maxRunning)
+	}
+	select {
+	case <-executor.Done():
+	default:
+		t.Error("done was not signalled")
+	}
+}
@@ -0,0 +1,241 @@
+/*
+Copyright Alex Mack and Michael Lawson (michael@sphinix.com)
+This file is part of Orca.
+
+Orca is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Orca is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Orca.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package client
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"orcahostd/model"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+/* Where files without a ContainerPath end up, HostPath is taken relative to it */
+const legacyFilesPath = "/orcatmp"
+
+const defaultFileMode = 0644
+
+/* The path of the file inside the container */
+func fileContainerPath(file model.File) string {
+	if file.ContainerPath != "" {
+		return path.Clean(file.ContainerPath)
+	}
+	return path.Join(legacyFilesPath, path.Clean("/" + file.HostPath))
+}
+
+/* The mode of the file, Mode is octal like 0640 */
+func fileMode(file model.File) (os.FileMode, error) {
+	if file.Mode == "" {
+		return defaultFileMode, nil
+	}
+	mode, err := strconv.ParseUint(file.Mode, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("Invalid file mode %q, expected octal permissions like 0640", file.Mode)
+	}
+	return os.FileMode(mode), nil
+}
+
+/* The numeric owner of the file, -1 keeps the owner of the host process */
+func fileOwner(file model.File) (int, int, error) {
+	if file.Owner == "" {
+		return -1, -1, nil
+	}
+	parts := strings.SplitN(file.Owner, ":", 2)
+	uid, err := strconv.Atoi(parts[0])
+	if err != nil || uid < 0 {
+		return 0, 0, fmt.Errorf("Invalid file owner %q, expected uid or uid:gid", file.Owner)
+	}
+	gid := -1
+	if len(parts) == 2 {
+		gid, err = strconv.Atoi(parts[1])
+		if err != nil || gid < 0 {
+			return 0, 0, fmt.Errorf("Invalid file owner %q, expected uid or uid:gid", file.Owner)
+		}
+	}
+	return uid, gid, nil
+}
+
+/* Checks the files of the config */
+func validateFiles(config model.VersionConfig) error {
+	seen := make(map[string]bool)
+	for _, file := range config.Files {
+		if file.ContainerPath == "" && file.HostPath == "" {
+			return fmt.Errorf("File without a container path")
+		}
+		if file.ContainerPath != "" && !path.IsAbs(file.ContainerPath) {
+			return fmt.Errorf("Container path %q of a file is not absolute", file.ContainerPath)
+		}
+		target := fileContainerPath(file)
+		if seen[target] {
+			return fmt.Errorf("File %s is given twice", target)
+		}
+		seen[target] = true
+		if _, err := base64.StdEncoding.DecodeString(file.Base64FileContents); err != nil {
+			return fmt.Errorf("File %s is not valid base64: %s", target, err)
+		}
+		if _, err := fileMode(file); err != nil {
+			return err
+		}
+		if _, _, err := fileOwner(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/* FileStore materializes the Files of a config on the host. Every instance gets its own
+directory below dir/<app>/<DockerAppId> and every file is bind mounted on its own, so the
+rest of the container path stays as the image left it. */
+type FileStore struct {
+	dir string
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (store *FileStore) instanceDir(app string, appId string) string {
+	return filepath.Join(store.dir, app, appId)
+}
+
+/* Writes the files of the config for the instance and returns the mounts for them */
+func (store *FileStore) Write(app string, appId string, files []model.File) ([]model.VolumeMapping, error) {
+	mounts := make([]model.VolumeMapping, 0)
+	if len(files) == 0 {
+		return mounts, nil
+	}
+
+	dir := store.instanceDir(app, appId)
+	for _, file := range files {
+		target := fileContainerPath(file)
+		hostPath := filepath.Join(dir, filepath.FromSlash(target))
+		if err := writeFile(hostPath, file); err != nil {
+			return nil, fmt.Errorf("Could not write file %s: %s", target, err)
+		}
+		mounts = append(mounts, model.VolumeMapping{HostPath: hostPath, ContainerPath: target, ReadOnly: true})
+	}
+	return mounts, nil
+}
+
+/* Writes the file next to its final path and renames it into place, so a container
+never sees a partially written file */
+func writeFile(hostPath string, file model.File) error {
+	contents, err := base64.StdEncoding.DecodeString(file.Base64FileContents)
+	if err != nil {
+		return err
+	}
+	mode, err := fileMode(file)
+	if err != nil {
+		return err
+	}
+	uid, gid, err := fileOwner(file)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(hostPath), 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(hostPath), filepath.Base(hostPath) + ".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(contents); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	/* TempFile creates the file with 0600, set the mode explicitly so the umask does not apply */
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	if uid >= 0 {
+		if err := os.Chown(tmp.Name(), uid, gid); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp.Name(), hostPath)
+}
+
+/* Removes the files of one instance */
+func (store *FileStore) RemoveInstance(app string, appId string) error {
+	return os.RemoveAll(store.instanceDir(app, appId))
+}
+
+/* Removes the files of every instance of the app */
+func (store *FileStore) RemoveApp(app string) error {
+	return os.RemoveAll(filepath.Join(store.dir, app))
+}
+
+/* Removes the instance directories whose container is in none of keep, keyed by DockerAppId */
+func (store *FileStore) Collect(keep map[string]bool) []string {
+	removed := make([]string, 0)
+	apps, _ := ioutil.ReadDir(store.dir)
+	for _, app := range apps {
+		if !app.IsDir() {
+			continue
+		}
+		appDir := filepath.Join(store.dir, app.Name())
+		instances, _ := ioutil.ReadDir(appDir)
+		for _, instance := range instances {
+			if !keep[instance.Name()] {
+				os.RemoveAll(filepath.Join(appDir, instance.Name()))
+				removed = append(removed, instance.Name())
+			}
+		}
+		/* Only succeeds once the directory is empty */
+		os.Remove(appDir)
+	}
+	return removed
+}
+
+/* Removes the files of containers that no longer exist, left behind by a crash between
+removing a container and its files */
+func (client *Client) collectFiles() {
+	containers, err := client.engine.ListApps()
+	if err != nil {
+		return
+	}
+	keep := make(map[string]bool)
+	for _, container := range containers {
+		keep[container.DockerAppId] = true
+	}
+	for _, state := range client.appStates() {
+		keep[state.DockerAppId] = true
+	}
+	if removed := client.files.Collect(keep); len(removed) > 0 {
+		ClientLogger.Infof("Removed the files of gone containers %v", removed)
+	}
+}
@@ -0,0 +1,96 @@
+package client
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"orcahostd/model"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateFiles(t *testing.T) {
+	contents := base64.StdEncoding.EncodeToString([]byte("key=value"))
+	cases := []struct {
+		file  model.File
+		valid bool
+	}{
+		{model.File{HostPath: "/app.conf", Base64FileContents: contents}, true},
+		{model.File{ContainerPath: "/etc/app/app.conf", Base64FileContents: contents, Mode: "0640", Owner: "1000:1000"}, true},
+		{model.File{Base64FileContents: contents}, false},
+		{model.File{ContainerPath: "etc/app.conf", Base64FileContents: contents}, false},
+		{model.File{ContainerPath: "/app.conf", Base64FileContents: "not base64!"}, false},
+		{model.File{ContainerPath: "/app.conf", Mode: "600"}, true},
+		{model.File{ContainerPath: "/app.conf", Mode: "0999"}, false},
+		{model.File{ContainerPath: "/app.conf", Owner: "app"}, false},
+	}
+	for _, c := range cases {
+		if err := validateFiles(model.VersionConfig{Files: []model.File{c.file}}); (err == nil) != c.valid {
+			t.Error(c.file, err)
+		}
+	}
+}
+
+func TestFileContainerPath_StaysBelowOrcatmp(t *testing.T) {
+	if target := fileContainerPath(model.File{HostPath: "../../etc/passwd"}); target != "/orcatmp/etc/passwd" {
+		t.Error(target)
+	}
+	if target := fileContainerPath(model.File{HostPath: "/conf/app.conf", ContainerPath: "/etc/app/../app.conf"}); target != "/etc/app.conf" {
+		t.Error(target)
+	}
+}
+
+func TestDeployApp_Files_WrittenMountedAndRemoved(t *testing.T) {
+	engine := newFakeEngine()
+	client, cleanup := newTestClient(t, engine)
+	defer cleanup()
+
+	config := model.VersionConfig{Version: "1", Files: []model.File{
+		{ContainerPath: "/etc/app/app.conf", Base64FileContents: base64.StdEncoding.EncodeToString([]byte("key=value")), Mode: "0640"},
+		{HostPath: "/legacy.conf", Base64FileContents: base64.StdEncoding.EncodeToString([]byte("old"))},
+	}}
+	client.HandleRequestedChanges([]model.Change{{Id: "1", Type: "add_application", Name: "app1", AppConfig: config}})
+	waitForChanges(t, client)
+
+	states := client.GetAppState()
+	if len(states) != 1 {
+		t.Fatal(states, client.GetChangeLog())
+	}
+	mounts := engine.mounts[states[0].DockerAppId]
+	if len(mounts) != 2 || mounts[0].ContainerPath != "/etc/app/app.conf" || mounts[1].ContainerPath != "/orcatmp/legacy.conf" || !mounts[0].ReadOnly {
+		t.Fatal(mounts)
+	}
+	contents, err := ioutil.ReadFile(mounts[0].HostPath)
+	if err != nil || string(contents) != "key=value" {
+		t.Error(string(contents), err)
+	}
+	if info, err := os.Stat(mounts[0].HostPath); err != nil || info.Mode().Perm() != 0640 {
+		t.Error(info, err)
+	}
+
+	client.HandleRequestedChanges([]model.Change{{Id: "2", Type: "remove_application", Name: "app1"}})
+	waitForChanges(t, client)
+	if _, err := os.Stat(filepath.Join(client.files.dir, "app1")); !os.IsNotExist(err) {
+		t.Error("files of the app were not removed", err)
+	}
+}
+
+func TestFileStore_Collect_KeepsKnownInstances(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orcahostd-files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, _ := NewFileStore(dir)
+	files := []model.File{{ContainerPath: "/app.conf"}}
+	store.Write("app1", "app1-a", files)
+	store.Write("app1", "app1-b", files)
+
+	removed := store.Collect(map[string]bool{"app1-a": true})
+	if len(removed) != 1 || removed[0] != "app1-b" {
+		t.Error(removed)
+	}
+	if _, err := os.Stat(store.instanceDir("app1", "app1-a")); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,141 @@
+/*
+Copyright Alex Mack and Michael Lawson (michael@sphinix.com)
+This file is part of Orca.
+
+Orca is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Orca is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Orca.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package client
+
+import (
+	"orcahostd/docker"
+	"orcahostd/model"
+	"time"
+)
+
+const (
+	DefaultKeepImages = 3
+	DefaultDiskPressure = 80
+	imageCollectInterval = 10 * time.Minute
+)
+
+/* An image an application version ran from */
+type ImageRecord struct {
+	Version string
+	Image   string
+	ImageId string
+	Used    time.Time
+}
+
+/* Remembers that the app version ran from the image, the history stays ordered by last use */
+func (client *Client) recordImage(name string, config model.VersionConfig, imageId string) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	image := docker.ImageName(config.DockerConfig)
+	history := make([]ImageRecord, 0)
+	for _, record := range client.Images[name] {
+		if record.Image != image {
+			history = append(history, record)
+		}
+	}
+	client.Images[name] = append(history, ImageRecord{Version: config.Version, Image: image, ImageId: imageId, Used: time.Now()})
+}
+
+/* Images of each app that are older than its last keep versions and not its last known
+good version */
+func (client *Client) expiredImages(keep int) map[string][]ImageRecord {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	ret := make(map[string][]ImageRecord)
+	for name, history := range client.Images {
+		/* The most recently used versions come last */
+		recent := make(map[string]bool)
+		for i := len(history) - 1; i >= 0 && len(recent) < keep; i-- {
+			recent[history[i].Version] = true
+		}
+		lastKnownGood := client.LastKnownGood[name]
+		for _, record := range history {
+			if !recent[record.Version] && record.Version != lastKnownGood.Version {
+				ret[name] = append(ret[name], record)
+			}
+		}
+	}
+	return ret
+}
+
+func (client *Client) forgetImage(name string, image string) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	history := make([]ImageRecord, 0)
+	for _, record := range client.Images[name] {
+		if record.Image != image {
+			history = append(history, record)
+		}
+	}
+	if len(history) == 0 {
+		delete(client.Images, name)
+	} else {
+		client.Images[name] = history
+	}
+}
+
+/* CollectImages removes the images of all but the last keepImages versions of every app.
+Images used by any container on the host are never removed. Returns the number of removed images. */
+func (client *Client) CollectImages() int {
+	inUse, err := client.engine.ImagesInUse()
+	if err != nil {
+		ClientLogger.Errorf("Not collecting images, could not tell which are in use: %s", err)
+		return 0
+	}
+	for _, state := range client.appStates() {
+		inUse[client.copyState(state).ImageId] = true
+	}
+
+	removed := 0
+	for name, records := range client.expiredImages(client.keepImages) {
+		for _, record := range records {
+			if inUse[record.Image] || (record.ImageId != "" && inUse[record.ImageId]) {
+				continue
+			}
+			ClientLogger.Infof("Removing image %s of app %s version %s", record.Image, name, record.Version)
+			if err := client.engine.RemoveImage(record.Image); err != nil {
+				continue
+			}
+			client.forgetImage(name, record.Image)
+			removed++
+		}
+	}
+	if removed > 0 {
+		client.persist()
+	}
+	return removed
+}
+
+/* Collects images whenever the disk usage of the host reaches the disk pressure
+threshold, it never returns */
+func (client *Client) CollectImagesOnPressure() {
+	ticker := time.NewTicker(imageCollectInterval)
+	for range ticker.C {
+		/* HardDiskUsagePercent is in hundredths of a percent */
+		usage := client.engine.HostMetrics().HardDiskUsagePercent
+		if usage >= int64(client.diskPressure) * 100 {
+			ClientLogger.Warnf("Disk usage is at %d.%02d%%, collecting images", usage / 100, usage % 100)
+			client.CollectImages()
+		}
+	}
+}
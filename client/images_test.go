@@ -0,0 +1,77 @@
+package client
+
+import (
+	"orcahostd/model"
+	"testing"
+)
+
+func TestDeployApp_ReportsImageAndDigest(t *testing.T) {
+	client, cleanup := newTestClient(t, newFakeEngine())
+	defer cleanup()
+
+	config := model.VersionConfig{Version: "1", DockerConfig: model.DockerConfig{Repository: "orca/app", Tag: "latest", Reference: "sha256:abc"}}
+	client.HandleRequestedChanges([]model.Change{{Id: "1", Type: "add_application", Name: "app1", AppConfig: config}})
+	waitForChanges(t, client)
+
+	state := client.GetAppState()[0]
+	if state.ImageId != "image-orca/app@sha256:abc" || state.Digest != "sha256:abc" {
+		t.Error(state)
+	}
+}
+
+func TestCollectImages_KeepsRecentAndInUse(t *testing.T) {
+	engine := newFakeEngine()
+	client, cleanup := newTestClient(t, engine)
+	defer cleanup()
+	client.keepImages = 1
+
+	version := func(v string) model.VersionConfig {
+		return model.VersionConfig{Version: v, DockerConfig: model.DockerConfig{Repository: "orca/app", Tag: v}}
+	}
+	client.HandleRequestedChanges([]model.Change{
+		{Id: "1", Type: "add_application", Name: "app1", AppConfig: version("1")},
+		{Id: "2", Type: "add_application", Name: "app2", AppConfig: version("1")},
+	})
+	waitForChanges(t, client)
+	client.HandleRequestedChanges([]model.Change{{Id: "3", Type: "update_application", Name: "app1", AppConfig: version("2")}})
+	waitForChanges(t, client)
+	client.HandleRequestedChanges([]model.Change{{Id: "4", Type: "update_application", Name: "app1", AppConfig: version("3")}})
+	waitForChanges(t, client)
+
+	/* Version 1 is still run by app2, version 3 is the one to keep */
+	if removed := client.CollectImages(); removed != 1 {
+		t.Error(removed)
+	}
+	if len(engine.removedImages) != 1 || engine.removedImages[0] != "orca/app:2" {
+		t.Error(engine.removedImages)
+	}
+	if history := client.Images["app1"]; len(history) != 2 || history[0].Version != "1" || history[1].Version != "3" {
+		t.Error(history)
+	}
+}
+
+func TestPrepullImage_PullsWithoutStarting(t *testing.T) {
+	engine := newFakeEngine()
+	client, cleanup := newTestClient(t, engine)
+	defer cleanup()
+
+	config := model.VersionConfig{Version: "2", DockerConfig: model.DockerConfig{Repository: "orca/app", Tag: "2"}}
+	client.HandleRequestedChanges([]model.Change{{Id: "1", Type: "prepull_image", Name: "app1", AppConfig: config}})
+	waitForChanges(t, client)
+
+	if result := client.GetChangeLog()["1"]; result.Status != model.ChangeSucceeded {
+		t.Error(result)
+	}
+	if engine.count() != 0 || len(client.GetAppState()) != 0 {
+		t.Error("pre-pulling started the app")
+	}
+	if pull := client.GetPullProgress()["app1"]; pull.Image != "orca/app:2" || pull.Status != model.PullDone {
+		t.Error(pull)
+	}
+
+	client.HandleRequestedChanges([]model.Change{{Id: "2", Type: "remove_application", Name: "app1"}})
+	waitForChanges(t, client)
+	if pulls := client.GetPullProgress(); len(pulls) != 0 {
+		t.Error("pull of a removed app is still reported", pulls)
+	}
+}
@@ -0,0 +1,124 @@
+/*
+Copyright Alex Mack and Michael Lawson (michael@sphinix.com)
+This file is part of Orca.
+
+Orca is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Orca is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Orca.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package client
+
+import (
+	"errors"
+	"orcahostd/model"
+)
+
+/* The lifecycle operations act on the existing containers of an app, its configuration
+and container ids stay the same. */
+
+/* The instances of an app, an error if there are none */
+func (client *Client) instances(name string) ([]*model.ApplicationState, error) {
+	ret := make([]*model.ApplicationState, 0)
+	for _, state := range client.appStates() {
+		if state.Name == name {
+			ret = append(ret, state)
+		}
+	}
+	if len(ret) == 0 {
+		return nil, errors.New("No application " + name)
+	}
+	return ret, nil
+}
+
+func (client *Client) setStopped(state *model.ApplicationState, stopped bool) {
+	client.updateState(state, func(state *model.ApplicationState) {
+		state.Stopped = stopped
+	})
+	client.persist()
+}
+
+/* Waits for the checks of restarted instances */
+func (client *Client) awaitRestarted(name string, states []*model.ApplicationState) error {
+	config, _ := client.configuration(name)
+	for _, state := range states {
+		if !client.awaitChecks(state, config) {
+			return phaseError(model.PhaseCheck, errors.New("Checks did not pass"))
+		}
+	}
+	return nil
+}
+
+func (client *Client) RestartApp(name string) error {
+	ClientLogger.Infof("Restarting app %s", name)
+	states, err := client.instances(name)
+	if err != nil {
+		return err
+	}
+
+	for _, state := range states {
+		if err := client.engine.RestartApp(state.DockerAppId); err != nil {
+			return phaseError(model.PhaseStart, err)
+		}
+		client.setStopped(state, false)
+	}
+	return client.awaitRestarted(name, states)
+}
+
+func (client *Client) StopApp(name string) error {
+	ClientLogger.Infof("Stopping app %s", name)
+	states, err := client.instances(name)
+	if err != nil {
+		return err
+	}
+
+	for _, state := range states {
+		/* Mark it first so nobody reports or treats the app as failed while it stops */
+		client.setStopped(state, true)
+		if err := client.engine.StopApp(state.DockerAppId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (client *Client) StartApp(name string) error {
+	ClientLogger.Infof("Starting app %s", name)
+	states, err := client.instances(name)
+	if err != nil {
+		return err
+	}
+
+	for _, state := range states {
+		if err := client.engine.StartApp(state.DockerAppId); err != nil {
+			return phaseError(model.PhaseStart, err)
+		}
+		client.setStopped(state, false)
+	}
+	return client.awaitRestarted(name, states)
+}
+
+func (client *Client) SignalApp(name string, signal string) error {
+	ClientLogger.Infof("Sending %s to app %s", signal, name)
+	states, err := client.instances(name)
+	if err != nil {
+		return err
+	}
+
+	for _, state := range states {
+		if err := client.engine.SignalApp(state.DockerAppId, signal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package client
+
+import (
+	"orcahostd/model"
+	"testing"
+)
+
+func TestLifecycle_StopAndStart_KeepsContainer(t *testing.T) {
+	engine := newFakeEngine()
+	client, cleanup := newTestClient(t, engine)
+	defer cleanup()
+
+	client.HandleRequestedChanges([]model.Change{{Id: "1", Type: "add_application", Name: "app1", AppConfig: model.VersionConfig{Version: "1"}}})
+	waitForChanges(t, client)
+	id := client.GetAppState()[0].DockerAppId
+
+	client.HandleRequestedChanges([]model.Change{{Id: "2", Type: "stop_application", Name: "app1"}})
+	waitForChanges(t, client)
+	if state := client.GetAppState()[0]; state.Application.State != "stopped" || !state.Stopped {
+		t.Error(state)
+	}
+
+	client.HandleRequestedChanges([]model.Change{
+		{Id: "3", Type: "start_application", Name: "app1"},
+		{Id: "4", Type: "signal_application", Name: "app1", Signal: "SIGHUP"},
+		{Id: "5", Type: "restart_application", Name: "missing"},
+	})
+	waitForChanges(t, client)
+
+	state := client.GetAppState()[0]
+	if state.DockerAppId != id || state.Application.State != "running" || state.Stopped {
+		t.Error(state)
+	}
+	results := client.GetChangeLog()
+	if results["3"].Status != model.ChangeSucceeded || results["4"].Status != model.ChangeSucceeded {
+		t.Error(results)
+	}
+	if results["5"].Status != model.ChangeFailed {
+		t.Error(results["5"])
+	}
+}
@@ -0,0 +1,204 @@
+/*
+Copyright Alex Mack and Michael Lawson (michael@sphinix.com)
+This file is part of Orca.
+
+Orca is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Orca is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Orca.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package client
+
+import (
+	"orcahostd/model"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMonitorInterval = 10 * time.Second
+	defaultMonitorFailureThreshold = 3
+	defaultReadinessFailureThreshold = 1
+	/* Check results kept and reported per instance */
+	MonitorHistorySize = 20
+)
+
+/* The debounced outcome of one check of one instance */
+type checkRecord struct {
+	kind      string
+	passing   bool
+	successes int
+	failures  int
+}
+
+type monitoredInstance struct {
+	/* By index of the check in the config, nil for startup checks */
+	records []*checkRecord
+	history []model.CheckResult
+	stop    chan struct{}
+}
+
+/* HealthMonitor runs the liveness and readiness checks of every instance in the background,
+each check on its own interval. An instance only changes between healthy and unhealthy after a check failed
+or passed as often in a row as its thresholds ask for, so a single failed probe does not
+flip its state. */
+type HealthMonitor struct {
+	run func(appId string, check model.ApplicationChecks) model.CheckResult
+
+	mutex     sync.Mutex
+	instances map[string]*monitoredInstance
+}
+
+func NewHealthMonitor(run func(appId string, check model.ApplicationChecks) model.CheckResult) *HealthMonitor {
+	return &HealthMonitor{run: run, instances: make(map[string]*monitoredInstance)}
+}
+
+/* Starts watching the instance, replacing an earlier watch of it. Its liveness checks start
+out passing if healthy is set, initial holds the results of the checks run while deploying.
+Readiness checks always start out failing, the instance only becomes ready once they passed
+as often as their SuccessThreshold asks for. */
+func (monitor *HealthMonitor) Watch(appId string, config model.VersionConfig, healthy bool, initial []model.CheckResult) {
+	instance := &monitoredInstance{history: make([]model.CheckResult, 0), stop: make(chan struct{})}
+	for _, check := range config.Checks {
+		var record *checkRecord
+		if check.Kind != model.CheckStartup {
+			record = &checkRecord{kind: check.Kind, passing: healthy && check.Kind != model.CheckReadiness}
+		}
+		instance.records = append(instance.records, record)
+	}
+	for _, result := range initial {
+		instance.add(result)
+	}
+
+	monitor.mutex.Lock()
+	if old, ok := monitor.instances[appId]; ok {
+		close(old.stop)
+	}
+	monitor.instances[appId] = instance
+	monitor.mutex.Unlock()
+
+	for index, check := range config.Checks {
+		if instance.records[index] != nil {
+			go monitor.watchCheck(appId, instance, index, check)
+		}
+	}
+}
+
+/* Stops watching the instance and forgets its results */
+func (monitor *HealthMonitor) Unwatch(appId string) {
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+	if instance, ok := monitor.instances[appId]; ok {
+		close(instance.stop)
+		delete(monitor.instances, appId)
+	}
+}
+
+func (monitor *HealthMonitor) watchCheck(appId string, instance *monitoredInstance, index int, check model.ApplicationChecks) {
+	interval := defaultMonitorInterval
+	if check.Interval > 0 {
+		interval = time.Duration(check.Interval) * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-instance.stop:
+			return
+		case <-ticker.C:
+		}
+		result := monitor.run(appId, check)
+
+		monitor.mutex.Lock()
+		instance.record(index, check, result)
+		monitor.mutex.Unlock()
+	}
+}
+
+/* Adds a result to the history, dropping the oldest once it is full */
+func (instance *monitoredInstance) add(result model.CheckResult) {
+	instance.history = append(instance.history, result)
+	if len(instance.history) > MonitorHistorySize {
+		instance.history = instance.history[len(instance.history) - MonitorHistorySize:]
+	}
+}
+
+func (instance *monitoredInstance) record(index int, check model.ApplicationChecks, result model.CheckResult) {
+	instance.add(result)
+
+	failureThreshold := check.FailureThreshold
+	if failureThreshold < 1 && check.Kind == model.CheckReadiness {
+		failureThreshold = defaultReadinessFailureThreshold
+	} else if failureThreshold < 1 {
+		failureThreshold = defaultMonitorFailureThreshold
+	}
+	record := instance.records[index]
+	if result.Passed {
+		record.failures = 0
+		record.successes++
+		if record.successes >= successThreshold(check) {
+			record.passing = true
+		}
+	} else {
+		record.successes = 0
+		record.failures++
+		if record.failures >= failureThreshold {
+			record.passing = false
+		}
+	}
+}
+
+/* Whether all liveness checks of the instance pass, watched is false for instances the
+monitor does not know */
+func (monitor *HealthMonitor) Health(appId string) (healthy bool, watched bool) {
+	return monitor.passing(appId, func(kind string) bool {
+		return kind != model.CheckReadiness
+	})
+}
+
+/* Whether all readiness checks of the instance pass */
+func (monitor *HealthMonitor) Ready(appId string) bool {
+	ready, watched := monitor.passing(appId, func(kind string) bool {
+		return kind == model.CheckReadiness
+	})
+	return ready && watched
+}
+
+func (monitor *HealthMonitor) passing(appId string, include func(kind string) bool) (bool, bool) {
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+
+	instance, ok := monitor.instances[appId]
+	if !ok {
+		return false, false
+	}
+	for _, record := range instance.records {
+		if record != nil && include(record.kind) && !record.passing {
+			return false, true
+		}
+	}
+	return true, true
+}
+
+/* The latest check results of the instance, oldest first */
+func (monitor *HealthMonitor) Results(appId string) []model.CheckResult {
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+
+	ret := make([]model.CheckResult, 0)
+	if instance, ok := monitor.instances[appId]; ok {
+		ret = append(ret, instance.history...)
+	}
+	return ret
+}
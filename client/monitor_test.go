@@ -0,0 +1,139 @@
+package client
+
+import (
+	"orcahostd/model"
+	"testing"
+	"time"
+)
+
+func TestMonitoredInstance_Debounced(t *testing.T) {
+	check := model.ApplicationChecks{Type: "tcp", FailureThreshold: 2, SuccessThreshold: 2}
+	monitor := NewHealthMonitor(nil)
+	instance := &monitoredInstance{records: []*checkRecord{{kind: model.CheckLiveness, passing: true}}}
+	monitor.instances["app1"] = instance
+
+	instance.record(0, check, model.CheckResult{Passed: false})
+	if healthy, _ := monitor.Health("app1"); !healthy {
+		t.Error("one failure should not make the instance unhealthy")
+	}
+	instance.record(0, check, model.CheckResult{Passed: false})
+	if healthy, _ := monitor.Health("app1"); healthy {
+		t.Error("two failures should make the instance unhealthy")
+	}
+	instance.record(0, check, model.CheckResult{Passed: true})
+	if healthy, _ := monitor.Health("app1"); healthy {
+		t.Error("one success should not make the instance healthy")
+	}
+	instance.record(0, check, model.CheckResult{Passed: true})
+	if healthy, _ := monitor.Health("app1"); !healthy {
+		t.Error("two successes should make the instance healthy")
+	}
+
+	for i := 0; i < MonitorHistorySize + 5; i++ {
+		instance.record(0, check, model.CheckResult{Passed: true})
+	}
+	if results := monitor.Results("app1"); len(results) != MonitorHistorySize {
+		t.Error(len(results))
+	}
+	if _, watched := monitor.Health("app2"); watched {
+		t.Error("unknown instances are not watched")
+	}
+}
+
+func TestHealthMonitor_RunsChecksInBackground(t *testing.T) {
+	monitor := NewHealthMonitor(func(appId string, check model.ApplicationChecks) model.CheckResult {
+		return model.CheckResult{Passed: false, Time: time.Now()}
+	})
+
+	config := model.VersionConfig{Checks: []model.ApplicationChecks{{Type: "tcp", Interval: 1, FailureThreshold: 1}}}
+	monitor.Watch("app1", config, true, nil)
+	if healthy, watched := monitor.Health("app1"); !healthy || !watched {
+		t.Error("instances start out healthy")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if healthy, _ := monitor.Health("app1"); !healthy {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("monitor did not run the check")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	monitor.Unwatch("app1")
+	if _, watched := monitor.Health("app1"); watched {
+		t.Error("instance is still watched")
+	}
+	if len(monitor.Results("app1")) != 0 {
+		t.Error("results were kept")
+	}
+}
+
+func TestHealthMonitor_ReadinessSeparateFromLiveness(t *testing.T) {
+	readiness := model.ApplicationChecks{Kind: model.CheckReadiness, Type: "tcp"}
+	liveness := model.ApplicationChecks{Kind: model.CheckLiveness, Type: "tcp", FailureThreshold: 1}
+	monitor := NewHealthMonitor(nil)
+	instance := &monitoredInstance{records: []*checkRecord{
+		nil,
+		{kind: model.CheckReadiness, passing: true},
+		{kind: model.CheckLiveness, passing: true},
+	}}
+	monitor.instances["app1"] = instance
+
+	instance.record(1, readiness, model.CheckResult{Passed: false})
+	if monitor.Ready("app1") {
+		t.Error("failed readiness check should make the instance not ready")
+	}
+	if healthy, _ := monitor.Health("app1"); !healthy {
+		t.Error("failed readiness check should not make the instance unhealthy")
+	}
+
+	instance.record(2, liveness, model.CheckResult{Passed: false})
+	if healthy, _ := monitor.Health("app1"); healthy {
+		t.Error("failed liveness check should make the instance unhealthy")
+	}
+}
+
+func TestDeployApp_OnlyStartupChecksGate(t *testing.T) {
+	client, cleanup := newTestClient(t, newFakeEngine())
+	defer cleanup()
+
+	config := model.VersionConfig{Version: "1", Checks: []model.ApplicationChecks{
+		{Kind: model.CheckStartup, Type: "exec", Goal: "true"},
+		{Kind: model.CheckLiveness, Type: "tcp", Goal: "127.0.0.1:1", Interval: 60},
+		{Kind: model.CheckReadiness, Type: "tcp", Goal: "127.0.0.1:1", Interval: 60},
+	}}
+	client.HandleRequestedChanges([]model.Change{{Id: "1", Type: "add_application", Name: "app1", AppConfig: config}})
+	waitForChanges(t, client)
+
+	if result := client.GetChangeLog()["1"]; result.Status != model.ChangeSucceeded {
+		t.Error(result)
+	}
+	state := client.GetAppState()[0]
+	/* Running, but not ready before the readiness check ever passed */
+	if state.Application.State != "running" || state.Ready || len(state.Application.CheckResults) != 1 {
+		t.Error(state)
+	}
+}
+
+func TestHealthMonitor_ReadyOnlyAfterSuccessThreshold(t *testing.T) {
+	readiness := model.ApplicationChecks{Kind: model.CheckReadiness, Type: "tcp", Interval: 60, SuccessThreshold: 2}
+	monitor := NewHealthMonitor(nil)
+	monitor.Watch("app1", model.VersionConfig{Checks: []model.ApplicationChecks{readiness}}, true, nil)
+	defer monitor.Unwatch("app1")
+
+	if monitor.Ready("app1") {
+		t.Error("ready before the readiness check ran")
+	}
+	instance := monitor.instances["app1"]
+	instance.record(0, readiness, model.CheckResult{Passed: true})
+	if monitor.Ready("app1") {
+		t.Error("ready before the success threshold was reached")
+	}
+	instance.record(0, readiness, model.CheckResult{Passed: true})
+	if !monitor.Ready("app1") {
+		t.Error("not ready after the success threshold was reached")
+	}
+}
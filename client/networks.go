@@ -0,0 +1,88 @@
+/*
+Copyright Alex Mack and Michael Lawson (michael@sphinix.com)
+This file is part of Orca.
+
+Orca is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Orca is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Orca.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package client
+
+import (
+	"fmt"
+	"orcahostd/model"
+)
+
+/* Docker's own networks, aliases only work on user defined ones */
+var reservedNetworks = map[string]bool{"bridge": true, "host": true, "none": true, "default": true}
+
+/* Checks the networks of the config */
+func validateNetworks(config model.VersionConfig) error {
+	seen := make(map[string]bool)
+	for _, network := range config.Networks {
+		if network.Name == "" {
+			return fmt.Errorf("Network without a name")
+		}
+		if reservedNetworks[network.Name] {
+			return fmt.Errorf("Network %s is reserved by docker, use a user defined network", network.Name)
+		}
+		if seen[network.Name] {
+			return fmt.Errorf("Network %s is given twice", network.Name)
+		}
+		seen[network.Name] = true
+		for _, alias := range network.Aliases {
+			if alias == "" {
+				return fmt.Errorf("Empty alias on network %s", network.Name)
+			}
+		}
+	}
+	return nil
+}
+
+/* Creates the networks of the config that do not exist yet */
+func (client *Client) ensureNetworks(name string, config model.VersionConfig) error {
+	for _, network := range config.Networks {
+		if err := client.engine.EnsureNetwork(network.Name); err != nil {
+			ClientLogger.Errorf("Could not create network %s for app %s: %s", network.Name, name, err)
+			return err
+		}
+	}
+	return nil
+}
+
+/* Creates the networks of the config and the container attached to them. Changes of other
+apps may collect networks in parallel, they wait until the container uses the networks. */
+func (client *Client) createOnNetworks(appId string, name string, config model.VersionConfig, labels map[string]string) error {
+	client.networkMutex.RLock()
+	defer client.networkMutex.RUnlock()
+	if err := client.ensureNetworks(name, config); err != nil {
+		return err
+	}
+	return client.engine.CreateApp(appId, name, config, labels)
+}
+
+/* Removes the networks we created that no container uses anymore */
+func (client *Client) CollectNetworks() []string {
+	client.networkMutex.Lock()
+	defer client.networkMutex.Unlock()
+	removed, err := client.engine.RemoveUnusedNetworks()
+	if err != nil {
+		ClientLogger.Errorf("Could not collect unused networks: %s", err)
+		return nil
+	}
+	if len(removed) > 0 {
+		ClientLogger.Infof("Removed unused networks %v", removed)
+	}
+	return removed
+}
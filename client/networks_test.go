@@ -0,0 +1,129 @@
+package client
+
+import (
+	"orcahostd/model"
+	"testing"
+	"time"
+)
+
+func TestValidateNetworks(t *testing.T) {
+	cases := []struct {
+		networks []model.NetworkAttachment
+		valid    bool
+	}{
+		{nil, true},
+		{[]model.NetworkAttachment{{Name: "backend", Aliases: []string{"api"}}, {Name: "frontend"}}, true},
+		{[]model.NetworkAttachment{{Name: ""}}, false},
+		{[]model.NetworkAttachment{{Name: "bridge"}}, false},
+		{[]model.NetworkAttachment{{Name: "backend"}, {Name: "backend"}}, false},
+		{[]model.NetworkAttachment{{Name: "backend", Aliases: []string{""}}}, false},
+	}
+	for _, c := range cases {
+		if err := validateNetworks(model.VersionConfig{Networks: c.networks}); (err == nil) != c.valid {
+			t.Error(c.networks, err)
+		}
+	}
+}
+
+func TestDeployApp_Networks_CreatedAndCollected(t *testing.T) {
+	engine := newFakeEngine()
+	client, cleanup := newTestClient(t, engine)
+	defer cleanup()
+
+	/* Internal ports bind nothing on the host, so replicas can share them */
+	config := model.VersionConfig{Version: "1", Replicas: 2,
+		Networks: []model.NetworkAttachment{{Name: "backend", Aliases: []string{"api"}}},
+		PortMappings: []model.PortMapping{{HostPort: "8080", ContainerPort: "8080/tcp", Internal: true}},
+	}
+	client.HandleRequestedChanges([]model.Change{{Id: "1", Type: "add_application", Name: "app1", AppConfig: config}})
+	waitForChanges(t, client)
+
+	if result := client.GetChangeLog()["1"]; result.Status != model.ChangeSucceeded {
+		t.Fatal(result)
+	}
+	if !engine.networks["backend"] || len(engine.attached) != 2 {
+		t.Error(engine.networks, engine.attached)
+	}
+	for _, state := range client.GetAppState() {
+		if len(state.Ports) != 1 || state.Ports[0].HostPort != "" || !state.Ports[0].Internal {
+			t.Error(state.Ports)
+		}
+	}
+
+	client.HandleRequestedChanges([]model.Change{{Id: "2", Type: "remove_application", Name: "app1"}})
+	waitForChanges(t, client)
+	if engine.networks["backend"] {
+		t.Error("unused network was not removed")
+	}
+}
+
+func TestDeployApp_NetworkFails_CreatePhase(t *testing.T) {
+	engine := newFakeEngine()
+	engine.failNetwork = true
+	client, cleanup := newTestClient(t, engine)
+	defer cleanup()
+
+	config := model.VersionConfig{Version: "1", Networks: []model.NetworkAttachment{{Name: "backend"}}}
+	client.HandleRequestedChanges([]model.Change{{Id: "1", Type: "add_application", Name: "app1", AppConfig: config}})
+	waitForChanges(t, client)
+
+	if result := client.GetChangeLog()["1"]; result.Status != model.ChangeFailed || result.Phase != model.PhaseCreate {
+		t.Error(result)
+	}
+	if len(engine.containers) != 0 {
+		t.Error(engine.containers)
+	}
+}
+
+func TestCollectNetworks_WaitsForContainerCreation(t *testing.T) {
+	engine := newFakeEngine()
+	client, cleanup := newTestClient(t, engine)
+	defer cleanup()
+
+	/* Another app collects networks while the container is about to be created */
+	collected := make(chan struct{})
+	engine.beforeCreate = func() {
+		go func() {
+			client.CollectNetworks()
+			close(collected)
+		}()
+		time.Sleep(20 * time.Millisecond)
+	}
+	config := model.VersionConfig{Version: "1", Networks: []model.NetworkAttachment{{Name: "backend"}}}
+	client.HandleRequestedChanges([]model.Change{{Id: "1", Type: "add_application", Name: "app1", AppConfig: config}})
+	waitForChanges(t, client)
+	<-collected
+
+	if result := client.GetChangeLog()["1"]; result.Status != model.ChangeSucceeded {
+		t.Error(result)
+	}
+	if !engine.networks["backend"] {
+		t.Error("network in use was collected")
+	}
+}
+
+func TestCollectNetworks_KeepsNetworksOfStoppedApps(t *testing.T) {
+	engine := newFakeEngine()
+	client, cleanup := newTestClient(t, engine)
+	defer cleanup()
+
+	networks := []model.NetworkAttachment{{Name: "backend"}}
+	client.HandleRequestedChanges([]model.Change{
+		{Id: "1", Type: "add_application", Name: "app1", AppConfig: model.VersionConfig{Version: "1", Networks: networks}},
+		{Id: "2", Type: "add_application", Name: "app2", AppConfig: model.VersionConfig{Version: "1", Networks: []model.NetworkAttachment{{Name: "frontend"}}}},
+	})
+	waitForChanges(t, client)
+	client.HandleRequestedChanges([]model.Change{{Id: "3", Type: "stop_application", Name: "app1"}})
+	waitForChanges(t, client)
+	client.HandleRequestedChanges([]model.Change{{Id: "4", Type: "remove_application", Name: "app2"}})
+	waitForChanges(t, client)
+
+	if !engine.networks["backend"] || engine.networks["frontend"] {
+		t.Error(engine.networks)
+	}
+	client.HandleRequestedChanges([]model.Change{{Id: "5", Type: "start_application", Name: "app1"}})
+	waitForChanges(t, client)
+	if result := client.GetChangeLog()["5"]; result.Status != model.ChangeSucceeded {
+		t.Error(result)
+	}
+}
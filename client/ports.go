@@ -0,0 +1,150 @@
+/*
+Copyright Alex Mack and Michael Lawson (michael@sphinix.com)
+This file is part of Orca.
+
+Orca is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Orca is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Orca.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package client
+
+import (
+	"fmt"
+	"net"
+	"orcahostd/model"
+	"strconv"
+	"strings"
+)
+
+/* HostPort value asking for a port from the host's port range, an empty HostPort means the same */
+const HostPortAuto = "auto"
+
+/* Host ports handed out for auto port mappings, both ends included */
+type PortRange struct {
+	From int
+	To int
+}
+
+var DefaultPortRange = PortRange{From: 20000, To: 29999}
+
+/* Parses a port range in the 20000-29999 form */
+func ParsePortRange(value string) (PortRange, error) {
+	parts := strings.SplitN(value, "-", 2)
+	if len(parts) != 2 {
+		return PortRange{}, fmt.Errorf("Invalid port range %q, expected from-to", value)
+	}
+	from, fromErr := strconv.Atoi(strings.TrimSpace(parts[0]))
+	to, toErr := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if fromErr != nil || toErr != nil || from < 1 || to > 65535 || from > to {
+		return PortRange{}, fmt.Errorf("Invalid port range %q", value)
+	}
+	return PortRange{From: from, To: to}, nil
+}
+
+func isAutoPort(hostPort string) bool {
+	return hostPort == "" || hostPort == HostPortAuto
+}
+
+/* Whether the mapping binds a fixed host port, internal mappings bind none */
+func fixedHostPort(mapping model.PortMapping) bool {
+	return !mapping.Internal && !isAutoPort(mapping.HostPort)
+}
+
+/* The protocol of a container port in the 8080/udp form, tcp if none is given */
+func portProtocol(containerPort string) string {
+	if i := strings.Index(containerPort, "/"); i >= 0 {
+		return containerPort[i + 1:]
+	}
+	return "tcp"
+}
+
+/* Whether nothing on the host listens on the port */
+func portFree(port int, protocol string) bool {
+	address := ":" + strconv.Itoa(port)
+	if protocol == "udp" {
+		conn, err := net.ListenPacket("udp", address)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return false
+	}
+	listener.Close()
+	return true
+}
+
+/* Host ports held by our instances, including those whose container is not started yet */
+func (client *Client) allocatedPorts() map[int]bool {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	used := make(map[int]bool)
+	for _, state := range client.AppState {
+		for _, mapping := range state.Ports {
+			if port, err := strconv.Atoi(mapping.HostPort); err == nil {
+				used[port] = true
+			}
+		}
+	}
+	return used
+}
+
+/* Returns the port mappings of the config with every auto host port replaced by a free
+port from the port range, internal mappings keep no host port. A port is free if none of our instances holds it, no running
+container publishes it and nothing listens on it. Callers hold portMutex until the
+resolved ports are recorded in AppState. */
+func (client *Client) resolvePorts(config model.VersionConfig) ([]model.PortMapping, error) {
+	used := client.allocatedPorts()
+	if published, err := client.engine.UsedHostPorts(); err == nil {
+		for port := range published {
+			used[port] = true
+		}
+	}
+	for _, mapping := range config.PortMappings {
+		if !fixedHostPort(mapping) {
+			continue
+		}
+		if port, err := strconv.Atoi(mapping.HostPort); err == nil {
+			used[port] = true
+		}
+	}
+
+	ret := make([]model.PortMapping, 0)
+	next := client.portRange.From
+	for _, mapping := range config.PortMappings {
+		if mapping.Internal {
+			ret = append(ret, model.PortMapping{ContainerPort: mapping.ContainerPort, Internal: true})
+			continue
+		}
+		if !isAutoPort(mapping.HostPort) {
+			ret = append(ret, mapping)
+			continue
+		}
+		for ; next <= client.portRange.To; next++ {
+			if !used[next] && portFree(next, portProtocol(mapping.ContainerPort)) {
+				break
+			}
+		}
+		if next > client.portRange.To {
+			return nil, fmt.Errorf("No free host port left in %d-%d for container port %s", client.portRange.From, client.portRange.To, mapping.ContainerPort)
+		}
+		used[next] = true
+		ret = append(ret, model.PortMapping{HostPort: strconv.Itoa(next), ContainerPort: mapping.ContainerPort})
+	}
+	return ret, nil
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"net"
+	"orcahostd/model"
+	"strconv"
+	"testing"
+)
+
+func TestParsePortRange(t *testing.T) {
+	ports, err := ParsePortRange("20000-20010")
+	if err != nil || ports.From != 20000 || ports.To != 20010 {
+		t.Error(ports, err)
+	}
+	for _, value := range []string{"20000", "a-b", "20010-20000", "0-10", "1-70000"} {
+		if _, err := ParsePortRange(value); err == nil {
+			t.Error("expected an error for", value)
+		}
+	}
+}
+
+func TestDeployApp_AutoPorts_Resolved(t *testing.T) {
+	engine := newFakeEngine()
+	client, cleanup := newTestClient(t, engine)
+	defer cleanup()
+	client.portRange = PortRange{From: 41000, To: 41010}
+
+	/* Taken by another container and by a listening socket */
+	engine.usedPorts[41000] = true
+	listener, err := net.Listen("tcp", ":41001")
+	if err != nil {
+		t.Skip("cannot listen on test port", err)
+	}
+	defer listener.Close()
+
+	config := model.VersionConfig{Version: "1", Replicas: 2, PortMappings: []model.PortMapping{
+		{HostPort: "", ContainerPort: "80/tcp"},
+		{HostPort: HostPortAuto, ContainerPort: "443/tcp"},
+	}}
+	client.HandleRequestedChanges([]model.Change{{Id: "1", Type: "add_application", Name: "app1", AppConfig: config}})
+	waitForChanges(t, client)
+
+	states := client.GetAppState()
+	if len(states) != 2 {
+		t.Fatal(states, client.GetChangeLog())
+	}
+	seen := make(map[string]bool)
+	for _, state := range states {
+		if len(state.Ports) != 2 {
+			t.Fatal(state.Ports)
+		}
+		for _, mapping := range state.Ports {
+			port, _ := strconv.Atoi(mapping.HostPort)
+			if port < 41002 || port > 41010 || seen[mapping.HostPort] {
+				t.Error("bad or duplicate host port", mapping)
+			}
+			seen[mapping.HostPort] = true
+		}
+	}
+}
+
+func TestDeployApp_AutoPortsExhausted_Fails(t *testing.T) {
+	engine := newFakeEngine()
+	client, cleanup := newTestClient(t, engine)
+	defer cleanup()
+	client.portRange = PortRange{From: 41020, To: 41020}
+	engine.usedPorts[41020] = true
+
+	config := model.VersionConfig{Version: "1", PortMappings: []model.PortMapping{{ContainerPort: "80/tcp"}}}
+	client.HandleRequestedChanges([]model.Change{{Id: "1", Type: "add_application", Name: "app1", AppConfig: config}})
+	waitForChanges(t, client)
+
+	result := client.GetChangeLog()["1"]
+	if result.Status != model.ChangeFailed || result.Phase != model.PhaseCreate {
+		t.Error(result)
+	}
+}
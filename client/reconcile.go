@@ -0,0 +1,134 @@
+/*
+Copyright Alex Mack and Michael Lawson (michael@sphinix.com)
+This file is part of Orca.
+
+Orca is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Orca is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Orca.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"orcahostd/docker"
+	"orcahostd/model"
+	"strconv"
+)
+
+/* Hash of the full version config, used to recognise a container that was started from it */
+func ConfigHash(config model.VersionConfig) string {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func ContainerLabels(name string, changeId string, config model.VersionConfig, instance int) map[string]string {
+	return map[string]string{
+		docker.LabelApp: name,
+		docker.LabelVersion: config.Version,
+		docker.LabelChangeId: changeId,
+		docker.LabelConfigHash: ConfigHash(config),
+		docker.LabelInstance: strconv.Itoa(instance),
+	}
+}
+
+/* Reconcile matches the labelled containers on the host against AppState. Containers
+we already track are adopted as they are. A container whose labels match the stored
+configuration of an application and claims an instance we do not track is adopted into
+AppState, this covers crashes between creating a container and recording it. Everything else is an
+orphan and is either removed or reported to the trainer. Instances in AppState whose container
+is gone, because it was removed while we were down, are dropped so the trainer deploys them again. */
+func (client *Client) Reconcile(removeOrphans bool) {
+	containers, err := client.engine.ListApps()
+	if err != nil {
+		ClientLogger.Errorf("Could not reconcile containers: %s", err)
+		return
+	}
+
+	tracked := make(map[string]bool)
+	trackedInstances := make(map[string]bool)
+	for _, state := range client.appStates() {
+		tracked[state.DockerAppId] = true
+		trackedInstances[state.Name + "/" + strconv.Itoa(state.Instance)] = true
+	}
+
+	orphans := make([]model.OrphanContainer, 0)
+	for _, container := range containers {
+		if tracked[container.DockerAppId] {
+			ClientLogger.Infof("Adopted container %s (running=%t)", container.DockerAppId, container.Running)
+			continue
+		}
+
+		name := container.Labels[docker.LabelApp]
+		config, known := client.configuration(name)
+		instance, _ := strconv.Atoi(container.Labels[docker.LabelInstance])
+		instanceKey := name + "/" + strconv.Itoa(instance)
+		if known && !trackedInstances[instanceKey] && ConfigHash(config) == container.Labels[docker.LabelConfigHash] {
+			ClientLogger.Infof("Adopted untracked container %s for app %s", container.DockerAppId, name)
+			state := &model.ApplicationState{
+				Name: name,
+				DockerAppId: container.DockerAppId,
+				Instance: instance,
+				Application: model.Application{
+					Name: name,
+					Version: container.Labels[docker.LabelVersion],
+					ChangeId: container.Labels[docker.LabelChangeId],
+				},
+			}
+			client.addAppState(state)
+			client.recordContainer(state)
+			tracked[container.DockerAppId] = true
+			trackedInstances[instanceKey] = true
+			continue
+		}
+
+		if removeOrphans {
+			ClientLogger.Warnf("Removing orphaned container %s of app %s", container.DockerAppId, name)
+			client.engine.RemoveApp(container.DockerAppId)
+			continue
+		}
+
+		ClientLogger.Warnf("Found orphaned container %s of app %s", container.DockerAppId, name)
+		orphans = append(orphans, model.OrphanContainer{
+			DockerAppId: container.DockerAppId,
+			Name: name,
+			Version: container.Labels[docker.LabelVersion],
+			ChangeId: container.Labels[docker.LabelChangeId],
+		})
+	}
+
+	existing := make(map[string]bool)
+	for _, container := range containers {
+		existing[container.DockerAppId] = true
+	}
+	for _, state := range client.appStates() {
+		if !existing[state.DockerAppId] {
+			ClientLogger.Warnf("Container %s of app %s is gone, dropping instance %d", state.DockerAppId, state.Name, state.Instance)
+			client.files.RemoveInstance(state.Name, state.DockerAppId)
+			client.delAppStateByDockerId(state.DockerAppId)
+		}
+	}
+
+	client.Orphans = orphans
+	client.persist()
+}
+
+func (client *Client) GetOrphans() []model.OrphanContainer {
+	return client.Orphans
+}
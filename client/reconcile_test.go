@@ -0,0 +1,87 @@
+package client
+
+import (
+	"orcahostd/model"
+	"testing"
+)
+
+func restartClient(client *Client, engine *fakeEngine, removeOrphans bool) *Client {
+	restarted := &Client{}
+	restarted.initWithEngine(Options{DataDir: client.store.dataDir, Workers: 1, RemoveOrphans: removeOrphans}, engine)
+	return restarted
+}
+
+func TestReconcile_UntrackedContainerWithMatchingConfig_Adopted(t *testing.T) {
+	engine := newFakeEngine()
+	client, cleanup := newTestClient(t, engine)
+	defer cleanup()
+
+	config := model.VersionConfig{Version: "1", Replicas: 2}
+	client.HandleRequestedChanges([]model.Change{{Id: "1", Type: "add_application", Name: "app1", AppConfig: model.VersionConfig{Version: "1"}}})
+	waitForChanges(t, client)
+	client.setConfiguration("app1", config)
+	client.persist()
+
+	/* Created for instance 1 right before a crash, never recorded */
+	engine.CreateApp("app1_untracked", "app1", config, ContainerLabels("app1", "2", config, 1))
+
+	restarted := restartClient(client, engine, false)
+	states := restarted.GetAppState()
+	if len(states) != 2 {
+		t.Fatal(states)
+	}
+	for _, state := range states {
+		if state.DockerAppId == "app1_untracked" && (state.Instance != 1 || state.Application.ChangeId != "2") {
+			t.Error(state)
+		}
+	}
+	if len(restarted.GetOrphans()) != 0 {
+		t.Error(restarted.GetOrphans())
+	}
+}
+
+func TestReconcile_Orphans_ReportedOrRemoved(t *testing.T) {
+	engine := newFakeEngine()
+	client, cleanup := newTestClient(t, engine)
+	defer cleanup()
+
+	/* Labelled with a config hash that no stored configuration has */
+	config := model.VersionConfig{Version: "7"}
+	engine.CreateApp("gone_1", "gone", config, ContainerLabels("gone", "9", config, 0))
+	engine.StartApp("gone_1")
+
+	reported := restartClient(client, engine, false)
+	orphans := reported.GetOrphans()
+	if len(orphans) != 1 || orphans[0].DockerAppId != "gone_1" || orphans[0].Version != "7" {
+		t.Error(orphans)
+	}
+	if !engine.QueryApp("gone_1") {
+		t.Error("reported orphan was removed")
+	}
+
+	removed := restartClient(client, engine, true)
+	if len(removed.GetOrphans()) != 0 || engine.QueryApp("gone_1") {
+		t.Error("orphan was not removed", removed.GetOrphans())
+	}
+}
+
+func TestReconcile_ContainerGone_InstanceDropped(t *testing.T) {
+	engine := newFakeEngine()
+	client, cleanup := newTestClient(t, engine)
+	defer cleanup()
+
+	client.HandleRequestedChanges([]model.Change{{Id: "1", Type: "add_application", Name: "app1", AppConfig: model.VersionConfig{Version: "1"}}})
+	waitForChanges(t, client)
+	state, err := client.GetAppStateIndividual("app1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* Removed by hand while orcahostd was down */
+	engine.RemoveApp(state.DockerAppId)
+
+	restarted := restartClient(client, engine, false)
+	if states := restarted.GetAppState(); len(states) != 0 {
+		t.Error(states)
+	}
+}
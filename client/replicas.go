@@ -0,0 +1,107 @@
+/*
+Copyright Alex Mack and Michael Lawson (michael@sphinix.com)
+This file is part of Orca.
+
+Orca is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Orca is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Orca.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package client
+
+import (
+	"fmt"
+	"orcahostd/model"
+	"sort"
+)
+
+/* Number of containers the config asks for */
+func replicas(config model.VersionConfig) int {
+	if config.Replicas < 1 {
+		return 1
+	}
+	return config.Replicas
+}
+
+/* Instance numbers from..to-1 */
+func instanceRange(from int, to int) []int {
+	ret := make([]int, 0)
+	for instance := from; instance < to; instance++ {
+		ret = append(ret, instance)
+	}
+	return ret
+}
+
+/* Several replicas cannot bind the same fixed host port, auto and internal ports are fine */
+func validateReplicas(config model.VersionConfig) error {
+	if replicas(config) == 1 {
+		return nil
+	}
+	for _, mapping := range config.PortMappings {
+		if fixedHostPort(mapping) {
+			return fmt.Errorf("Host port %s cannot be bound by %d replicas", mapping.HostPort, replicas(config))
+		}
+	}
+	return nil
+}
+
+/* Whether two configurations only differ in their replica count */
+func onlyReplicasDiffer(current model.VersionConfig, next model.VersionConfig) bool {
+	current.Replicas = 0
+	next.Replicas = 0
+	return ConfigHash(current) == ConfigHash(next)
+}
+
+/* ScaleApp starts or removes instances until the app runs as many as the config asks
+for. Running instances are left alone, instances are removed from the highest number down. */
+func (client *Client) ScaleApp(name string, changeId string, config model.VersionConfig) error {
+	if err := validateReplicas(config); err != nil {
+		return phaseError(model.PhaseCreate, err)
+	}
+	states, err := client.instances(name)
+	if err != nil {
+		return err
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Instance < states[j].Instance
+	})
+
+	want := replicas(config)
+	ClientLogger.Infof("Scaling app %s from %d to %d instances", name, len(states), want)
+	if len(states) > want {
+		client.removeInstances(states[want:])
+	} else if len(states) < want {
+		used := make(map[int]bool)
+		for _, state := range states {
+			used[state.Instance] = true
+		}
+		free := make([]int, 0)
+		for instance := 0; len(states) + len(free) < want; instance++ {
+			if !used[instance] {
+				free = append(free, instance)
+			}
+		}
+
+		newAppStates, changeErr := client.startAndCheck(name, changeId, config, free, nil)
+		if changeErr != nil {
+			ClientLogger.Errorf("Scaling app %s to %d instances failed: %s", name, want, changeErr)
+			client.removeInstances(newAppStates)
+			return changeErr
+		}
+	}
+
+	client.setConfiguration(name, config)
+	client.setLastKnownGood(name, config)
+	client.persist()
+	return nil
+}
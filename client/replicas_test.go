@@ -0,0 +1,56 @@
+package client
+
+import (
+	"orcahostd/model"
+	"testing"
+)
+
+func TestReplicas_DeployAndScale(t *testing.T) {
+	engine := newFakeEngine()
+	client, cleanup := newTestClient(t, engine)
+	defer cleanup()
+
+	client.HandleRequestedChanges([]model.Change{{Id: "1", Type: "add_application", Name: "app1", AppConfig: model.VersionConfig{Version: "1", Replicas: 3}}})
+	waitForChanges(t, client)
+
+	states := client.GetAppState()
+	if len(states) != 3 || engine.count() != 3 {
+		t.Fatal(states)
+	}
+	metrics := client.GetAppMetrics()
+	for instance, state := range states {
+		if state.Instance != instance {
+			t.Error(state)
+		}
+		if _, ok := metrics[state.DockerAppId]; !ok {
+			t.Error("no metrics for", state.DockerAppId)
+		}
+	}
+
+	client.HandleRequestedChanges([]model.Change{{Id: "2", Type: "update_application", Name: "app1", AppConfig: model.VersionConfig{Version: "1", Replicas: 1}}})
+	waitForChanges(t, client)
+
+	states = client.GetAppState()
+	if len(states) != 1 || states[0].Instance != 0 || states[0].Application.ChangeId != "1" {
+		t.Fatal(states)
+	}
+
+	client.HandleRequestedChanges([]model.Change{{Id: "3", Type: "update_application", Name: "app1", AppConfig: model.VersionConfig{Version: "1", Replicas: 2}}})
+	waitForChanges(t, client)
+	if states = client.GetAppState(); len(states) != 2 || states[1].Instance != 1 || states[1].Application.ChangeId != "3" {
+		t.Fatal(states)
+	}
+}
+
+func TestReplicas_FixedHostPort_Rejected(t *testing.T) {
+	client, cleanup := newTestClient(t, newFakeEngine())
+	defer cleanup()
+
+	config := model.VersionConfig{Version: "1", Replicas: 2, PortMappings: []model.PortMapping{{HostPort: "80", ContainerPort: "80/tcp"}}}
+	client.HandleRequestedChanges([]model.Change{{Id: "1", Type: "add_application", Name: "app1", AppConfig: config}})
+	waitForChanges(t, client)
+
+	if result := client.GetChangeLog()["1"]; result.Status != model.ChangeFailed || result.Phase != model.PhaseCreate {
+		t.Error(result)
+	}
+}
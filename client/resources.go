@@ -0,0 +1,134 @@
+/*
+Copyright Alex Mack and Michael Lawson (michael@sphinix.com)
+This file is part of Orca.
+
+Orca is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Orca is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Orca.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package client
+
+import (
+	"fmt"
+	"orcahostd/model"
+	"strconv"
+	"strings"
+)
+
+/* Docker refuses CPU quotas below a millisecond */
+const minCpuQuota = 1000
+
+/* The CPUs of a cpuset like 0-3,6 */
+func parseCpuset(cpuset string) ([]int, error) {
+	cpus := make([]int, 0)
+	for _, part := range strings.Split(cpuset, ",") {
+		bounds := strings.SplitN(strings.TrimSpace(part), "-", 2)
+		from, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid cpuset %q", cpuset)
+		}
+		to := from
+		if len(bounds) == 2 {
+			if to, err = strconv.Atoi(bounds[1]); err != nil || to < from {
+				return nil, fmt.Errorf("Invalid cpuset %q", cpuset)
+			}
+		}
+		for cpu := from; cpu <= to; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
+
+/* Checks that the limits are consistent and that the host can satisfy them */
+func validateResources(resources model.Resources, capacity model.HostCapacity) error {
+	if resources.Memory < 0 || resources.MemoryReservation < 0 || resources.CpuShares < 0 || resources.CpuQuota < 0 || resources.CpuPeriod < 0 {
+		return fmt.Errorf("Resource limits cannot be negative")
+	}
+	if capacity.Memory > 0 && resources.Memory > capacity.Memory {
+		return fmt.Errorf("Memory limit %d exceeds the %d bytes of the host", resources.Memory, capacity.Memory)
+	}
+	if capacity.Memory > 0 && resources.MemoryReservation > capacity.Memory {
+		return fmt.Errorf("Memory reservation %d exceeds the %d bytes of the host", resources.MemoryReservation, capacity.Memory)
+	}
+	if resources.Memory > 0 && resources.MemoryReservation > resources.Memory {
+		return fmt.Errorf("Memory reservation %d is above the memory limit %d", resources.MemoryReservation, resources.Memory)
+	}
+	if resources.MemorySwap != 0 && resources.MemorySwap != -1 {
+		if resources.Memory == 0 {
+			return fmt.Errorf("A swap limit needs a memory limit")
+		}
+		if resources.MemorySwap < resources.Memory {
+			return fmt.Errorf("Swap limit %d is below the memory limit %d, it counts memory and swap together", resources.MemorySwap, resources.Memory)
+		}
+	}
+
+	if resources.CpuQuota > 0 {
+		if resources.CpuQuota < minCpuQuota {
+			return fmt.Errorf("CPU quota %d is below the minimum of %d", resources.CpuQuota, minCpuQuota)
+		}
+		period := resources.CpuPeriod
+		if period == 0 {
+			period = 100000
+		}
+		if capacity.Cpus > 0 && resources.CpuQuota > period * int64(capacity.Cpus) {
+			return fmt.Errorf("CPU quota %d per period %d asks for more than the %d CPUs of the host", resources.CpuQuota, period, capacity.Cpus)
+		}
+	}
+	if resources.CpusetCpus != "" {
+		cpus, err := parseCpuset(resources.CpusetCpus)
+		if err != nil {
+			return err
+		}
+		for _, cpu := range cpus {
+			if capacity.Cpus > 0 && cpu >= capacity.Cpus {
+				return fmt.Errorf("Cpuset %s uses CPU %d, the host has %d", resources.CpusetCpus, cpu, capacity.Cpus)
+			}
+		}
+	}
+
+	if resources.BlkioWeight != 0 && (resources.BlkioWeight < 10 || resources.BlkioWeight > 1000) {
+		return fmt.Errorf("Block IO weight %d is not between 10 and 1000", resources.BlkioWeight)
+	}
+	for _, ulimit := range resources.Ulimits {
+		if ulimit.Name == "" || ulimit.Soft > ulimit.Hard {
+			return fmt.Errorf("Invalid ulimit %s, soft %d hard %d", ulimit.Name, ulimit.Soft, ulimit.Hard)
+		}
+	}
+	return nil
+}
+
+/* Checks the config can be run on this host before anything is pulled or started */
+func (client *Client) validateConfig(config model.VersionConfig) error {
+	if err := validateReplicas(config); err != nil {
+		return err
+	}
+	if err := validateRuntime(config); err != nil {
+		return err
+	}
+	if err := validateNetworks(config); err != nil {
+		return err
+	}
+	if err := validateVolumes(config); err != nil {
+		return err
+	}
+	if err := validateFiles(config); err != nil {
+		return err
+	}
+	capacity, err := client.engine.HostCapacity()
+	if err != nil {
+		ClientLogger.Warnf("Could not read host capacity, only checking limits for consistency: %s", err)
+	}
+	return validateResources(config.Resources, capacity)
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"orcahostd/model"
+	"testing"
+)
+
+func TestValidateResources(t *testing.T) {
+	capacity := model.HostCapacity{Memory: 1 << 30, Cpus: 2}
+	cases := []struct {
+		resources model.Resources
+		valid     bool
+	}{
+		{model.Resources{}, true},
+		{model.Resources{Memory: 256 << 20, MemoryReservation: 128 << 20, MemorySwap: 512 << 20}, true},
+		{model.Resources{Memory: 2 << 30}, false},
+		{model.Resources{Memory: 128 << 20, MemoryReservation: 256 << 20}, false},
+		{model.Resources{MemorySwap: 512 << 20}, false},
+		{model.Resources{Memory: 256 << 20, MemorySwap: 128 << 20}, false},
+		{model.Resources{Memory: 256 << 20, MemorySwap: -1}, true},
+		{model.Resources{CpuQuota: 150000}, true},
+		{model.Resources{CpuQuota: 300000}, false},
+		{model.Resources{CpuQuota: 500}, false},
+		{model.Resources{CpusetCpus: "0-1"}, true},
+		{model.Resources{CpusetCpus: "0,2"}, false},
+		{model.Resources{CpusetCpus: "a"}, false},
+		{model.Resources{BlkioWeight: 5}, false},
+		{model.Resources{PidsLimit: 100, Ulimits: []model.Ulimit{{Name: "nofile", Soft: 1024, Hard: 4096}}}, true},
+		{model.Resources{Ulimits: []model.Ulimit{{Name: "nofile", Soft: 4096, Hard: 1024}}}, false},
+	}
+	for _, c := range cases {
+		if err := validateResources(c.resources, capacity); (err == nil) != c.valid {
+			t.Error(c.resources, err)
+		}
+	}
+}
+
+func TestDeployApp_ResourcesAppliedAndReported(t *testing.T) {
+	client, cleanup := newTestClient(t, newFakeEngine())
+	defer cleanup()
+
+	resources := model.Resources{Memory: 256 << 20, CpuShares: 512, PidsLimit: 100}
+	client.HandleRequestedChanges([]model.Change{
+		{Id: "1", Type: "add_application", Name: "app1", AppConfig: model.VersionConfig{Version: "1", Resources: resources}},
+		{Id: "2", Type: "add_application", Name: "app2", AppConfig: model.VersionConfig{Version: "1", Resources: model.Resources{Memory: 4 << 30}}},
+	})
+	waitForChanges(t, client)
+
+	if state, _ := client.GetAppStateIndividual("app1"); state.Resources.Memory != resources.Memory || state.Resources.PidsLimit != 100 {
+		t.Error(state.Resources)
+	}
+	if result := client.GetChangeLog()["2"]; result.Status != model.ChangeFailed || result.Phase != model.PhaseCreate {
+		t.Error(result)
+	}
+}
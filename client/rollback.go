@@ -0,0 +1,76 @@
+/*
+Copyright Alex Mack and Michael Lawson (michael@sphinix.com)
+This file is part of Orca.
+
+Orca is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Orca is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Orca.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package client
+
+import (
+	"fmt"
+	"orcahostd/model"
+	"time"
+)
+
+/* Replaces the failed instances of an app with its last known good configuration and
+returns the error to report for the change. Only once the restored instances pass their
+checks is the rollback recorded in Application.Rollback, which turns cause into a rolled
+back change. A failed rollback returns why it failed, the change is reported as failed. */
+func (client *Client) rollback(name string, changeId string, failed model.VersionConfig, failedInstances []*model.ApplicationState, cause *ChangeError) *ChangeError {
+	failedState := client.copyState(failedInstances[len(failedInstances) - 1])
+	previous, ok := client.lastKnownGood(name)
+	if !ok || ConfigHash(previous) == ConfigHash(failed) {
+		ClientLogger.Warnf("App %s failed with version %s and there is no previous version to roll back to", name, failed.Version)
+		return cause
+	}
+
+	ClientLogger.Warnf("App %s failed with version %s (%s), rolling back to %s", name, failed.Version, failedState.Application.State, previous.Version)
+	client.removeInstances(failedInstances)
+
+	client.setConfiguration(name, previous)
+	restored, changeErr := client.startAndCheck(name, changeId, previous, instanceRange(0, replicas(previous)), nil)
+	if changeErr != nil {
+		ClientLogger.Errorf("Rolling back app %s to %s failed: %s", name, previous.Version, changeErr)
+		client.persist()
+		return phaseError(changeErr.Phase, fmt.Errorf("%s, rolling back to %s failed: %s", cause.Err, previous.Version, changeErr.Err))
+	}
+	for _, state := range restored {
+		client.updateState(state, func(state *model.ApplicationState) {
+			state.Application.Rollback = &model.Rollback{
+				ChangeId: changeId,
+				FailedVersion: failed.Version,
+				FailedState: failedState.Application.State,
+				RestoredVersion: previous.Version,
+				Time: time.Now(),
+			}
+		})
+	}
+	client.persist()
+
+	ClientLogger.Infof("Rolled back app %s to %s", name, previous.Version)
+	return cause
+}
+
+/* Whether the given change ended with the app being rolled back to its previous version */
+func (client *Client) RolledBack(name string, changeId string) bool {
+	for _, instance := range client.appStates() {
+		state := client.copyState(instance)
+		if state.Name == name && state.Application.Rollback != nil && state.Application.Rollback.ChangeId == changeId {
+			return true
+		}
+	}
+	return false
+}
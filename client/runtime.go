@@ -0,0 +1,70 @@
+/*
+Copyright Alex Mack and Michael Lawson (michael@sphinix.com)
+This file is part of Orca.
+
+Orca is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Orca is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Orca.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package client
+
+import (
+	"fmt"
+	"net"
+	"orcahostd/docker"
+	"orcahostd/model"
+	"strings"
+)
+
+/* Checks the runtime options of the config */
+func validateRuntime(config model.VersionConfig) error {
+	for key := range config.Labels {
+		if strings.HasPrefix(key, docker.LabelPrefix) {
+			return fmt.Errorf("Label %s is reserved, %s labels are set by the host", key, docker.LabelPrefix)
+		}
+	}
+	for _, entry := range config.ExtraHosts {
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || net.ParseIP(parts[1]) == nil {
+			return fmt.Errorf("Invalid extra host %q, expected host:ip", entry)
+		}
+	}
+	for _, server := range config.Dns {
+		if net.ParseIP(server) == nil {
+			return fmt.Errorf("Invalid DNS server %q", server)
+		}
+	}
+
+	policy := config.RestartPolicy
+	if policy == nil {
+		return nil
+	}
+	switch policy.Name {
+	case model.RestartNo:
+	case model.RestartAlways, model.RestartUnlessStopped, model.RestartOnFailure:
+		/* Docker and the supervisor would both restart the container */
+		if config.Supervisor != nil {
+			return fmt.Errorf("Restart policy %s cannot be combined with the supervisor", policy.Name)
+		}
+	default:
+		return fmt.Errorf("Unknown restart policy %q", policy.Name)
+	}
+	if policy.MaximumRetryCount != 0 && policy.Name != model.RestartOnFailure {
+		return fmt.Errorf("A maximum retry count needs the %s restart policy", model.RestartOnFailure)
+	}
+	if policy.MaximumRetryCount < 0 {
+		return fmt.Errorf("Maximum retry count cannot be negative")
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package client
+
+import (
+	"orcahostd/model"
+	"testing"
+)
+
+func TestValidateRuntime(t *testing.T) {
+	cases := []struct {
+		config model.VersionConfig
+		valid  bool
+	}{
+		{model.VersionConfig{}, true},
+		{model.VersionConfig{Command: []string{"serve"}, User: "1000:1000", WorkingDir: "/app", Labels: map[string]string{"team": "core"}}, true},
+		{model.VersionConfig{Labels: map[string]string{"orca.app": "other"}}, false},
+		{model.VersionConfig{ExtraHosts: []string{"db:10.0.0.5"}, Dns: []string{"8.8.8.8"}}, true},
+		{model.VersionConfig{ExtraHosts: []string{"db"}}, false},
+		{model.VersionConfig{Dns: []string{"dns.local"}}, false},
+		{model.VersionConfig{RestartPolicy: &model.RestartPolicy{Name: model.RestartOnFailure, MaximumRetryCount: 3}}, true},
+		{model.VersionConfig{RestartPolicy: &model.RestartPolicy{Name: model.RestartAlways, MaximumRetryCount: 3}}, false},
+		{model.VersionConfig{RestartPolicy: &model.RestartPolicy{Name: "sometimes"}}, false},
+		{model.VersionConfig{RestartPolicy: &model.RestartPolicy{Name: model.RestartAlways}, Supervisor: &model.SupervisorPolicy{}}, false},
+		{model.VersionConfig{RestartPolicy: &model.RestartPolicy{Name: model.RestartNo}, Supervisor: &model.SupervisorPolicy{}}, true},
+	}
+	for _, c := range cases {
+		if err := validateRuntime(c.config); (err == nil) != c.valid {
+			t.Error(c.config, err)
+		}
+	}
+}
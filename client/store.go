@@ -0,0 +1,119 @@
+/*
+Copyright Alex Mack and Michael Lawson (michael@sphinix.com)
+This file is part of Orca.
+
+Orca is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Orca is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Orca.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package client
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"orcahostd/model"
+	"os"
+	"path/filepath"
+)
+
+const stateFileName = "state.json"
+
+/* Everything the client needs to remember across restarts */
+type persistedState struct {
+	AppState         []*model.ApplicationState
+	AppConfiguration map[string]model.VersionConfig
+	ChangeResults    map[string]model.ChangeResult
+	LastKnownGood    map[string]model.VersionConfig
+	Images           map[string][]ImageRecord
+}
+
+/* StateStore keeps a JSON snapshot of the client state under the data dir. Every
+save writes a temporary file, syncs it and renames it over the old snapshot, so a
+crash leaves either the previous or the new state on disk, never a partial one. */
+type StateStore struct {
+	dataDir string
+}
+
+func NewStateStore(dataDir string) (*StateStore, error) {
+	if err := os.MkdirAll(dataDir, 0700); err != nil {
+		return nil, err
+	}
+	return &StateStore{dataDir: dataDir}, nil
+}
+
+func (store *StateStore) path() string {
+	return filepath.Join(store.dataDir, stateFileName)
+}
+
+/* Load fills state from the snapshot. A missing snapshot is not an error, the
+state is simply left as it is. */
+func (store *StateStore) Load(state *persistedState) error {
+	data, err := ioutil.ReadFile(store.path())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, state)
+}
+
+func (store *StateStore) Save(state *persistedState) error {
+	data, err := encodeState(state)
+	if err != nil {
+		return err
+	}
+	return store.write(data)
+}
+
+/* The snapshot as written to disk, encoding copies the state so it can be written later */
+func encodeState(state *persistedState) ([]byte, error) {
+	return json.MarshalIndent(state, "", "  ")
+}
+
+func (store *StateStore) write(data []byte) error {
+	tmp, err := ioutil.TempFile(store.dataDir, stateFileName + ".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), store.path()); err != nil {
+		return err
+	}
+
+	/* Make the rename itself durable */
+	dir, err := os.Open(store.dataDir)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+/* Moves an unreadable snapshot out of the way so it can be inspected later */
+func (store *StateStore) Quarantine() error {
+	return os.Rename(store.path(), store.path() + ".corrupt")
+}
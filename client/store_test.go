@@ -0,0 +1,77 @@
+package client
+
+import (
+	"io/ioutil"
+	"orcahostd/model"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStateStore_LoadMissing_LeavesStateUntouched(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "orcahostd")
+	defer os.RemoveAll(dir)
+
+	store, err := NewStateStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := persistedState{ChangeResults: map[string]model.ChangeResult{"a": {Status: model.ChangeSucceeded}}}
+	if err := store.Load(&state); err != nil {
+		t.Fatal(err)
+	}
+	if state.ChangeResults["a"].Status != model.ChangeSucceeded {
+		t.Error(state)
+	}
+}
+
+func TestStateStore_SaveAndLoad(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "orcahostd")
+	defer os.RemoveAll(dir)
+
+	store, _ := NewStateStore(filepath.Join(dir, "nested"))
+	saved := persistedState{
+		AppState: []*model.ApplicationState{{Name: "app1", DockerAppId: "app1_1"}},
+		AppConfiguration: map[string]model.VersionConfig{"app1": {Version: "2"}},
+		ChangeResults: map[string]model.ChangeResult{"change1": {Status: model.ChangeSucceeded, Attempts: 1}},
+	}
+	if err := store.Save(&saved); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := persistedState{}
+	if err := store.Load(&loaded); err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.AppState) != 1 || loaded.AppState[0].DockerAppId != "app1_1" {
+		t.Error(loaded.AppState)
+	}
+	if loaded.AppConfiguration["app1"].Version != "2" || loaded.ChangeResults["change1"].Status != model.ChangeSucceeded {
+		t.Error(loaded)
+	}
+
+	/* No temporary files may be left behind */
+	files, _ := ioutil.ReadDir(filepath.Join(dir, "nested"))
+	if len(files) != 1 {
+		t.Error(files)
+	}
+}
+
+func TestStateStore_LoadCorrupt_ReturnsError(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "orcahostd")
+	defer os.RemoveAll(dir)
+
+	store, _ := NewStateStore(dir)
+	ioutil.WriteFile(filepath.Join(dir, stateFileName), []byte("{not json"), 0600)
+
+	if err := store.Load(&persistedState{}); err == nil {
+		t.Error("expected an error")
+	}
+	if err := store.Quarantine(); err != nil {
+		t.Error(err)
+	}
+	if err := store.Load(&persistedState{}); err != nil {
+		t.Error(err)
+	}
+}
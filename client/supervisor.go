@@ -0,0 +1,160 @@
+/*
+Copyright Alex Mack and Michael Lawson (michael@sphinix.com)
+This file is part of Orca.
+
+Orca is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Orca is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Orca.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package client
+
+import (
+	"orcahostd/model"
+	"sync"
+	"time"
+)
+
+const (
+	superviseInterval = 5 * time.Second
+	defaultMaxRestarts = 5
+	defaultRestartWindow = 300 * time.Second
+	defaultInitialBackoff = 1 * time.Second
+	defaultMaxBackoff = 300 * time.Second
+)
+
+func seconds(value int, fallback time.Duration) time.Duration {
+	if value > 0 {
+		return time.Duration(value) * time.Second
+	}
+	return fallback
+}
+
+func maxRestarts(policy *model.SupervisorPolicy) int {
+	if policy.MaxRestarts > 0 {
+		return policy.MaxRestarts
+	}
+	return defaultMaxRestarts
+}
+
+/* How long to wait after the last of `restarts` restarts before restarting again */
+func backoff(policy *model.SupervisorPolicy, restarts int) time.Duration {
+	wait := seconds(policy.InitialBackoff, defaultInitialBackoff)
+	limit := seconds(policy.MaxBackoff, defaultMaxBackoff)
+	for i := 1; i < restarts && wait < limit; i++ {
+		wait *= 2
+	}
+	if wait > limit {
+		return limit
+	}
+	return wait
+}
+
+/* Supervisor remembers when instances were restarted, so restarts can back off and
+crash loops can be detected */
+type Supervisor struct {
+	mutex    sync.Mutex
+	restarts map[string][]time.Time
+}
+
+func NewSupervisor() *Supervisor {
+	return &Supervisor{restarts: make(map[string][]time.Time)}
+}
+
+/* The restarts of the instance within the window before now, oldest first */
+func (supervisor *Supervisor) recent(appId string, window time.Duration, now time.Time) []time.Time {
+	supervisor.mutex.Lock()
+	defer supervisor.mutex.Unlock()
+
+	ret := make([]time.Time, 0)
+	for _, restart := range supervisor.restarts[appId] {
+		if now.Sub(restart) < window {
+			ret = append(ret, restart)
+		}
+	}
+	supervisor.restarts[appId] = ret
+	return ret
+}
+
+func (supervisor *Supervisor) add(appId string, now time.Time) {
+	supervisor.mutex.Lock()
+	defer supervisor.mutex.Unlock()
+	supervisor.restarts[appId] = append(supervisor.restarts[appId], now)
+}
+
+/* Supervise checks the instances every few seconds, it never returns */
+func (client *Client) Supervise() {
+	ticker := time.NewTicker(superviseInterval)
+	for now := range ticker.C {
+		client.superviseOnce(now)
+	}
+}
+
+/* Restarts every supervised instance that died or failed its checks and whose backoff
+passed. Stopped instances and apps with pending changes are left alone. */
+func (client *Client) superviseOnce(now time.Time) {
+	for _, state := range client.appStates() {
+		current := client.copyState(state)
+		config, _ := client.configuration(current.Name)
+		policy := config.Supervisor
+		if policy == nil || current.Stopped || client.executor.Busy(current.Name) {
+			continue
+		}
+
+		info, err := client.engine.InspectApp(current.DockerAppId)
+		if err != nil {
+			continue
+		}
+		window := seconds(policy.Window, defaultRestartWindow)
+		recent := client.supervisor.recent(current.DockerAppId, window, now)
+		healthy, watched := client.monitor.Health(current.DockerAppId)
+		dockerUnhealthy := info.Health != nil && info.Health.Status == model.DockerHealthUnhealthy
+		if info.Running && (healthy || !watched) && !dockerUnhealthy {
+			if current.CrashLoop && len(recent) < maxRestarts(policy) {
+				ClientLogger.Infof("App %s instance %d left its crash loop", current.Name, current.Instance)
+				client.updateState(state, func(state *model.ApplicationState) {
+					state.CrashLoop = false
+				})
+				client.persist()
+			}
+			continue
+		}
+		if len(recent) > 0 && now.Before(recent[len(recent) - 1].Add(backoff(policy, len(recent)))) {
+			continue
+		}
+
+		if info.Running {
+			ClientLogger.Warnf("App %s instance %d failed its checks, restarting", current.Name, current.Instance)
+		} else {
+			ClientLogger.Warnf("App %s instance %d died with exit code %d, restarting", current.Name, current.Instance, info.ExitCode)
+		}
+		if err := client.engine.RestartApp(current.DockerAppId); err != nil {
+			ClientLogger.Errorf("Restarting app %s instance %d failed: %s", current.Name, current.Instance, err)
+		}
+		client.supervisor.add(current.DockerAppId, now)
+
+		crashLoop := len(recent) + 1 >= maxRestarts(policy)
+		if crashLoop && !current.CrashLoop {
+			ClientLogger.Errorf("App %s instance %d is crash looping, restarted %d times within %s", current.Name, current.Instance, len(recent) + 1, window)
+		}
+		client.updateState(state, func(state *model.ApplicationState) {
+			state.Restarts++
+			if !info.Running {
+				state.LastExitCode = info.ExitCode
+			}
+			state.CrashLoop = crashLoop
+		})
+		client.monitor.Watch(current.DockerAppId, config, true, nil)
+		client.persist()
+	}
+}
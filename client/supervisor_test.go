@@ -0,0 +1,85 @@
+package client
+
+import (
+	"orcahostd/model"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	policy := &model.SupervisorPolicy{InitialBackoff: 1, MaxBackoff: 5}
+	expected := []time.Duration{1, 1, 2, 4, 5, 5}
+	for restarts, want := range expected {
+		if got := backoff(policy, restarts); got != want * time.Second {
+			t.Error(restarts, got)
+		}
+	}
+}
+
+func TestSupervise_RestartsWithBackoffUntilCrashLoop(t *testing.T) {
+	engine := newFakeEngine()
+	client, cleanup := newTestClient(t, engine)
+	defer cleanup()
+
+	config := model.VersionConfig{Version: "1", Supervisor: &model.SupervisorPolicy{MaxRestarts: 3, InitialBackoff: 10}}
+	client.HandleRequestedChanges([]model.Change{{Id: "1", Type: "add_application", Name: "app1", AppConfig: config}})
+	waitForChanges(t, client)
+	id := client.GetAppState()[0].DockerAppId
+
+	now := time.Now()
+	engine.setRunning(id, false)
+	client.superviseOnce(now)
+	if !engine.QueryApp(id) {
+		t.Fatal("dead instance was not restarted")
+	}
+	state := client.GetAppState()[0]
+	if state.Restarts != 1 || state.LastExitCode != 1 || state.Application.State != "running" {
+		t.Error(state)
+	}
+
+	/* Within the backoff nothing happens */
+	engine.setRunning(id, false)
+	client.superviseOnce(now.Add(5 * time.Second))
+	if engine.QueryApp(id) {
+		t.Error("instance was restarted within its backoff")
+	}
+
+	client.superviseOnce(now.Add(11 * time.Second))
+	engine.setRunning(id, false)
+	client.superviseOnce(now.Add(32 * time.Second))
+	state = client.GetAppState()[0]
+	if state.Restarts != 3 || !state.CrashLoop || state.Application.State != "crash_loop" {
+		t.Error(state)
+	}
+
+	/* Once the window passed the instance leaves the crash loop */
+	client.superviseOnce(now.Add(time.Hour))
+	if state = client.GetAppState()[0]; state.CrashLoop || state.Application.State != "running" {
+		t.Error(state)
+	}
+}
+
+func TestSupervise_LeavesStoppedAndUnsupervisedAlone(t *testing.T) {
+	engine := newFakeEngine()
+	client, cleanup := newTestClient(t, engine)
+	defer cleanup()
+
+	supervised := model.VersionConfig{Version: "1", Supervisor: &model.SupervisorPolicy{}}
+	client.HandleRequestedChanges([]model.Change{
+		{Id: "1", Type: "add_application", Name: "app1", AppConfig: supervised},
+		{Id: "2", Type: "add_application", Name: "app2", AppConfig: model.VersionConfig{Version: "1"}},
+	})
+	waitForChanges(t, client)
+	client.HandleRequestedChanges([]model.Change{{Id: "3", Type: "stop_application", Name: "app1"}})
+	waitForChanges(t, client)
+
+	app2, _ := client.GetAppStateIndividual("app2")
+	engine.setRunning(app2.DockerAppId, false)
+	client.superviseOnce(time.Now())
+
+	for _, state := range client.GetAppState() {
+		if engine.QueryApp(state.DockerAppId) || state.Restarts != 0 {
+			t.Error(state)
+		}
+	}
+}
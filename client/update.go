@@ -0,0 +1,122 @@
+/*
+Copyright Alex Mack and Michael Lawson (michael@sphinix.com)
+This file is part of Orca.
+
+Orca is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Orca is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Orca.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package client
+
+import (
+	"fmt"
+	"orcahostd/model"
+	"time"
+)
+
+/* Values for VersionConfig.UpdateStrategy */
+const (
+	/* Start the new version next to the old one, the default */
+	UpdateStrategyStartFirst = "start_first"
+	/* Stop the old version first so the new one can take over its host ports */
+	UpdateStrategyStopFirst = "stop_first"
+)
+
+/* Fixed host ports that both configurations bind, they cannot run side by side */
+func hostPortClashes(current model.VersionConfig, next model.VersionConfig) []string {
+	used := make(map[string]bool)
+	for _, mapping := range current.PortMappings {
+		if fixedHostPort(mapping) {
+			used[mapping.HostPort] = true
+		}
+	}
+
+	clashes := make([]string, 0)
+	for _, mapping := range next.PortMappings {
+		if fixedHostPort(mapping) && used[mapping.HostPort] {
+			clashes = append(clashes, mapping.HostPort)
+		}
+	}
+	return clashes
+}
+
+/* UpdateApp replaces a running application with a new version. The new containers are
+started next to the old ones and the old ones are only removed once all new ones pass
+their checks, if they fail the old containers keep running. Applications binding the same
+host ports in both versions need the stop_first strategy, which hands the ports over.
+A config that only changes the replica count scales the app instead. */
+func (client *Client) UpdateApp(name string, changeId string, config model.VersionConfig) error {
+	olds, err := client.instances(name)
+	if err != nil {
+		ClientLogger.Infof("App %s is not running, deploying instead of updating", name)
+		return client.DeployApp(name, changeId, config)
+	}
+	old := olds[0]
+
+	current, _ := client.configuration(name)
+	if onlyReplicasDiffer(current, config) {
+		return client.ScaleApp(name, changeId, config)
+	}
+	if err := client.validateConfig(config); err != nil {
+		return phaseError(model.PhaseCreate, err)
+	}
+
+	clashes := hostPortClashes(current, config)
+	if len(clashes) > 0 && config.UpdateStrategy != UpdateStrategyStopFirst {
+		err := fmt.Errorf("Host ports %v are bound by the running version, use the %s update strategy", clashes, UpdateStrategyStopFirst)
+		ClientLogger.Errorf("Cannot update app %s to %s: %s", name, config.Version, err)
+		return phaseError(model.PhaseCreate, err)
+	}
+
+	ClientLogger.Infof("Updating app %s from %s to %s", name, old.Application.Version, config.Version)
+	pullErr := client.engine.InstallApp(name, config)
+
+	handover := len(clashes) > 0
+	if handover {
+		ClientLogger.Infof("Handing host ports %v of app %s over to version %s", clashes, name, config.Version)
+		for _, state := range olds {
+			client.engine.StopApp(state.DockerAppId)
+		}
+	}
+
+	newAppStates, changeErr := client.startAndCheck(name, changeId, config, instanceRange(0, replicas(config)), pullErr)
+	if changeErr != nil {
+		ClientLogger.Errorf("Update of app %s to %s failed, keeping version %s: %s", name, config.Version, old.Application.Version, changeErr)
+		failedState := client.copyState(newAppStates[len(newAppStates) - 1]).Application.State
+		client.removeInstances(newAppStates)
+		for _, state := range olds {
+			if handover {
+				client.engine.StartApp(state.DockerAppId)
+			}
+			client.updateState(state, func(state *model.ApplicationState) {
+				state.Application.Rollback = &model.Rollback{
+					ChangeId: changeId,
+					FailedVersion: config.Version,
+					FailedState: failedState,
+					RestoredVersion: state.Application.Version,
+					Time: time.Now(),
+				}
+			})
+		}
+		client.persist()
+		return changeErr
+	}
+
+	client.setConfiguration(name, config)
+	client.setLastKnownGood(name, config)
+	client.removeInstances(olds)
+
+	ClientLogger.Infof("Updated app %s to %s", name, config.Version)
+	return nil
+}
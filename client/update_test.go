@@ -0,0 +1,110 @@
+package client
+
+import (
+	"orcahostd/model"
+	"testing"
+)
+
+func deployVersion1(t *testing.T, client *Client, config model.VersionConfig) *model.ApplicationState {
+	client.HandleRequestedChanges([]model.Change{{Id: "1", Type: "add_application", Name: "app1", AppConfig: config}})
+	waitForChanges(t, client)
+	state, err := client.GetAppStateIndividual("app1")
+	if err != nil {
+		t.Fatal(err, client.GetChangeLog())
+	}
+	return state
+}
+
+func TestUpdateApp_FailedChecks_OldVersionKept(t *testing.T) {
+	engine := newFakeEngine()
+	client, cleanup := newTestClient(t, engine)
+	defer cleanup()
+	old := deployVersion1(t, client, model.VersionConfig{Version: "1"})
+
+	engine.mutex.Lock()
+	engine.execExitCode = 1
+	engine.mutex.Unlock()
+	next := model.VersionConfig{Version: "2", Checks: []model.ApplicationChecks{{Type: "exec", Goal: "pgrep worker", FailureThreshold: 1}}}
+	client.HandleRequestedChanges([]model.Change{{Id: "2", Type: "update_application", Name: "app1", AppConfig: next}})
+	waitForChanges(t, client)
+
+	/* The app still runs the old version, so the change counts as rolled back */
+	if result := client.GetChangeLog()["2"]; result.Status != model.ChangeRolledBack || result.Phase != model.PhaseCheck {
+		t.Error(result)
+	}
+	states := client.GetAppState()
+	if len(states) != 1 || states[0].DockerAppId != old.DockerAppId || states[0].Application.Version != "1" {
+		t.Fatal(states)
+	}
+	if rollback := states[0].Application.Rollback; rollback == nil || rollback.FailedVersion != "2" || rollback.RestoredVersion != "1" {
+		t.Error(rollback)
+	}
+	if len(engine.containers) != 1 || !engine.QueryApp(old.DockerAppId) {
+		t.Error("new container was not removed or old one stopped", engine.containers)
+	}
+}
+
+func TestUpdateApp_HostPortClash_NeedsStopFirst(t *testing.T) {
+	engine := newFakeEngine()
+	client, cleanup := newTestClient(t, engine)
+	defer cleanup()
+	ports := []model.PortMapping{{HostPort: "41100", ContainerPort: "80/tcp"}}
+	old := deployVersion1(t, client, model.VersionConfig{Version: "1", PortMappings: ports})
+
+	client.HandleRequestedChanges([]model.Change{{Id: "2", Type: "update_application", Name: "app1", AppConfig: model.VersionConfig{Version: "2", PortMappings: ports}}})
+	waitForChanges(t, client)
+
+	if result := client.GetChangeLog()["2"]; result.Status != model.ChangeFailed || result.Phase != model.PhaseCreate {
+		t.Error(result)
+	}
+	if state, _ := client.GetAppStateIndividual("app1"); state.DockerAppId != old.DockerAppId || !engine.QueryApp(old.DockerAppId) {
+		t.Error(state)
+	}
+}
+
+func TestUpdateApp_StopFirst_HandsPortsOver(t *testing.T) {
+	engine := newFakeEngine()
+	client, cleanup := newTestClient(t, engine)
+	defer cleanup()
+	ports := []model.PortMapping{{HostPort: "41100", ContainerPort: "80/tcp"}}
+	old := deployVersion1(t, client, model.VersionConfig{Version: "1", PortMappings: ports})
+
+	next := model.VersionConfig{Version: "2", PortMappings: ports, UpdateStrategy: UpdateStrategyStopFirst}
+	client.HandleRequestedChanges([]model.Change{{Id: "2", Type: "update_application", Name: "app1", AppConfig: next}})
+	waitForChanges(t, client)
+
+	if result := client.GetChangeLog()["2"]; result.Status != model.ChangeSucceeded {
+		t.Error(result)
+	}
+	states := client.GetAppState()
+	if len(states) != 1 || states[0].Application.Version != "2" || states[0].DockerAppId == old.DockerAppId {
+		t.Error(states)
+	}
+	if _, ok := engine.containers[old.DockerAppId]; ok {
+		t.Error("old container was not removed")
+	}
+}
+
+func TestUpdateApp_StopFirstFails_OldVersionRestarted(t *testing.T) {
+	engine := newFakeEngine()
+	engine.failCreate["2"] = true
+	client, cleanup := newTestClient(t, engine)
+	defer cleanup()
+	ports := []model.PortMapping{{HostPort: "41100", ContainerPort: "80/tcp"}}
+	old := deployVersion1(t, client, model.VersionConfig{Version: "1", PortMappings: ports})
+
+	next := model.VersionConfig{Version: "2", PortMappings: ports, UpdateStrategy: UpdateStrategyStopFirst}
+	client.HandleRequestedChanges([]model.Change{{Id: "2", Type: "update_application", Name: "app1", AppConfig: next}})
+	waitForChanges(t, client)
+
+	if result := client.GetChangeLog()["2"]; result.Status != model.ChangeRolledBack || result.Phase != model.PhaseCreate {
+		t.Error(result)
+	}
+	states := client.GetAppState()
+	if len(states) != 1 || states[0].DockerAppId != old.DockerAppId || states[0].Application.Version != "1" {
+		t.Fatal(states)
+	}
+	if !engine.QueryApp(old.DockerAppId) {
+		t.Error("old container was not started again")
+	}
+}
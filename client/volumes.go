@@ -0,0 +1,116 @@
+/*
+Copyright Alex Mack and Michael Lawson (michael@sphinix.com)
+This file is part of Orca.
+
+Orca is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Orca is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Orca.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package client
+
+import (
+	"fmt"
+	"orcahostd/docker"
+	"orcahostd/model"
+	"path"
+	"regexp"
+	"strings"
+)
+
+/* What docker accepts as a volume name, the app name is put in front of it */
+var volumeNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+/* Checks the named volumes and the volume mappings of the config */
+func validateVolumes(config model.VersionConfig) error {
+	declared := make(map[string]bool)
+	for _, volume := range config.Volumes {
+		if !volumeNamePattern.MatchString(volume.Name) {
+			return fmt.Errorf("Invalid volume name %q", volume.Name)
+		}
+		if declared[volume.Name] {
+			return fmt.Errorf("Volume %s is given twice", volume.Name)
+		}
+		declared[volume.Name] = true
+		for key := range volume.Labels {
+			if strings.HasPrefix(key, docker.LabelPrefix) {
+				return fmt.Errorf("Label %s of volume %s is reserved", key, volume.Name)
+			}
+		}
+	}
+
+	for _, mapping := range config.VolumeMappings {
+		if !path.IsAbs(mapping.ContainerPath) {
+			return fmt.Errorf("Container path %q of a volume mapping is not absolute", mapping.ContainerPath)
+		}
+		if mapping.Volume == "" {
+			if mapping.HostPath == "" {
+				return fmt.Errorf("Volume mapping for %s needs a host path or a volume", mapping.ContainerPath)
+			}
+			continue
+		}
+		if mapping.HostPath != "" {
+			return fmt.Errorf("Volume mapping for %s has both a host path and a volume", mapping.ContainerPath)
+		}
+		if !declared[mapping.Volume] {
+			return fmt.Errorf("Volume mapping for %s uses the undeclared volume %s", mapping.ContainerPath, mapping.Volume)
+		}
+	}
+	return nil
+}
+
+/* Creates the named volumes of the config that do not exist yet */
+func (client *Client) ensureVolumes(name string, config model.VersionConfig) error {
+	for _, volume := range config.Volumes {
+		if err := client.engine.EnsureVolume(name, volume); err != nil {
+			ClientLogger.Errorf("Could not create volume %s for app %s: %s", volume.Name, name, err)
+			return err
+		}
+	}
+	return nil
+}
+
+/* Deletes the named volumes of the app, only done on an explicit purge */
+func (client *Client) PurgeVolumes(name string) error {
+	removed, err := client.engine.RemoveVolumes(name)
+	if len(removed) > 0 {
+		ClientLogger.Infof("Purged volumes %v of app %s", removed, name)
+	}
+	if err != nil {
+		ClientLogger.Errorf("Could not purge the volumes of app %s: %s", name, err)
+	}
+	return err
+}
+
+/* The named volumes of every configured app, keyed by app name */
+func (client *Client) GetVolumeUsage() map[string][]model.VolumeUsage {
+	client.mutex.Lock()
+	apps := make([]string, 0)
+	for name, config := range client.AppConfiguration {
+		if len(config.Volumes) > 0 {
+			apps = append(apps, name)
+		}
+	}
+	client.mutex.Unlock()
+
+	ret := make(map[string][]model.VolumeUsage)
+	for _, name := range apps {
+		usage, err := client.engine.VolumeUsage(name)
+		if err != nil {
+			ClientLogger.Warnf("Could not read the volumes of app %s: %s", name, err)
+			continue
+		}
+		ret[name] = usage
+	}
+	return ret
+}
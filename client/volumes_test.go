@@ -0,0 +1,94 @@
+package client
+
+import (
+	"orcahostd/docker"
+	"orcahostd/model"
+	"testing"
+)
+
+func TestValidateVolumes(t *testing.T) {
+	data := []model.NamedVolume{{Name: "data"}}
+	cases := []struct {
+		config model.VersionConfig
+		valid  bool
+	}{
+		{model.VersionConfig{}, true},
+		{model.VersionConfig{VolumeMappings: []model.VolumeMapping{{HostPath: "/srv", ContainerPath: "/srv"}}}, true},
+		{model.VersionConfig{Volumes: data, VolumeMappings: []model.VolumeMapping{{Volume: "data", ContainerPath: "/data", ReadOnly: true}}}, true},
+		{model.VersionConfig{VolumeMappings: []model.VolumeMapping{{Volume: "data", ContainerPath: "/data"}}}, false},
+		{model.VersionConfig{Volumes: data, VolumeMappings: []model.VolumeMapping{{Volume: "data", HostPath: "/srv", ContainerPath: "/data"}}}, false},
+		{model.VersionConfig{Volumes: data, VolumeMappings: []model.VolumeMapping{{Volume: "data", ContainerPath: "data"}}}, false},
+		{model.VersionConfig{Volumes: []model.NamedVolume{{Name: "data"}, {Name: "data"}}}, false},
+		{model.VersionConfig{Volumes: []model.NamedVolume{{Name: "a/b"}}}, false},
+		{model.VersionConfig{Volumes: []model.NamedVolume{{Name: "data", Labels: map[string]string{"orca.app": "x"}}}}, false},
+	}
+	for _, c := range cases {
+		if err := validateVolumes(c.config); (err == nil) != c.valid {
+			t.Error(c.config, err)
+		}
+	}
+}
+
+func TestVolumes_KeptOnUpdate_RemovedOnPurge(t *testing.T) {
+	engine := newFakeEngine()
+	client, cleanup := newTestClient(t, engine)
+	defer cleanup()
+
+	config := model.VersionConfig{Version: "1",
+		Volumes: []model.NamedVolume{{Name: "data", Driver: "local"}},
+		VolumeMappings: []model.VolumeMapping{{Volume: "data", ContainerPath: "/data"}},
+	}
+	next := config
+	next.Version = "2"
+	client.HandleRequestedChanges([]model.Change{
+		{Id: "1", Type: "add_application", Name: "app1", AppConfig: config},
+		{Id: "2", Type: "update_application", Name: "app1", AppConfig: next},
+	})
+	waitForChanges(t, client)
+
+	if result := client.GetChangeLog()["2"]; result.Status != model.ChangeSucceeded {
+		t.Fatal(result)
+	}
+	usage := client.GetVolumeUsage()["app1"]
+	if len(usage) != 1 || usage[0].DockerName != docker.VolumeName("app1", "data") || usage[0].Containers != 1 {
+		t.Error(usage)
+	}
+
+	/* Removing without purge keeps the data */
+	client.HandleRequestedChanges([]model.Change{{Id: "3", Type: "remove_application", Name: "app1"}})
+	waitForChanges(t, client)
+	if len(engine.volumes) != 1 {
+		t.Error("volume removed without purge", engine.volumes)
+	}
+
+	client.HandleRequestedChanges([]model.Change{
+		{Id: "4", Type: "add_application", Name: "app1", AppConfig: config},
+		{Id: "5", Type: "remove_application", Name: "app1", Purge: true},
+	})
+	waitForChanges(t, client)
+	if result := client.GetChangeLog()["5"]; result.Status != model.ChangeSucceeded || len(engine.volumes) != 0 {
+		t.Error(result, engine.volumes)
+	}
+}
+
+func TestVolumes_NameOfOtherApp_NotReused(t *testing.T) {
+	engine := newFakeEngine()
+	client, cleanup := newTestClient(t, engine)
+	defer cleanup()
+
+	/* Both end up as the docker volume a_b_c */
+	first := model.VersionConfig{Version: "1", Volumes: []model.NamedVolume{{Name: "c"}}}
+	second := model.VersionConfig{Version: "1", Volumes: []model.NamedVolume{{Name: "b_c"}}}
+	client.HandleRequestedChanges([]model.Change{{Id: "1", Type: "add_application", Name: "a_b", AppConfig: first}})
+	waitForChanges(t, client)
+	client.HandleRequestedChanges([]model.Change{{Id: "2", Type: "add_application", Name: "a", AppConfig: second}})
+	waitForChanges(t, client)
+
+	results := client.GetChangeLog()
+	if results["1"].Status != model.ChangeSucceeded {
+		t.Error(results["1"])
+	}
+	if results["2"].Status != model.ChangeFailed || results["2"].Phase != model.PhaseCreate {
+		t.Error(results["2"])
+	}
+}
@@ -25,38 +25,185 @@ import (
 	"bytes"
 	"fmt"
 	"orcahostd/model"
-	"os"
 	"errors"
 	"github.com/shirou/gopsutil/mem"
 	"github.com/shirou/gopsutil/cpu"
 	"github.com/shirou/gopsutil/disk"
 	"time"
+	"sort"
+	"strings"
+	"sync"
+	"golang.org/x/net/context"
 )
 
 
 var DockerLogger = Logger.LoggerWithField(Logger.Logger, "module", "docker")
 
+/* Labels put on every container we create, used to find our containers after a restart */
+const (
+	LabelApp = "orca.app"
+	LabelVersion = "orca.version"
+	LabelChangeId = "orca.change_id"
+	LabelConfigHash = "orca.config_hash"
+	LabelInstance = "orca.instance"
+	/* Every label we set starts with this, configs cannot set such labels themselves */
+	LabelPrefix = "orca."
+)
+
+/* A container created by orcahostd, as found on the docker host */
+type ManagedContainer struct {
+	DockerAppId string
+	Running bool
+	Labels map[string]string
+}
+
+/* What docker reports about one of our containers */
+type AppInfo struct {
+	Running bool
+	/* Exit code of the main process, only meaningful when it is not running */
+	ExitCode int
+	/* Address of the container on its first network */
+	IP string
+	/* Nil if the image has no HEALTHCHECK */
+	Health *model.DockerHealth
+	ImageId string
+	/* The repository digest of the image, empty for images that were never pushed or pulled */
+	Digest string
+	Resources model.Resources
+	/* The host ports docker actually bound, ContainerPort is in the 8080/tcp form */
+	Ports []model.PortMapping
+}
+
+/* Outcome of a command run inside a container */
+type ExecResult struct {
+	ExitCode int
+	/* Stdout and stderr of the command, interleaved */
+	Output string
+}
+
+/* The image to run for the config, pinned by digest if the config has a Reference */
+func ImageName(config model.DockerConfig) string {
+	if config.Reference == "" {
+		return fmt.Sprintf("%s:%s", config.Repository, config.Tag)
+	}
+	if strings.Contains(config.Reference, "@") {
+		return config.Reference
+	}
+	return fmt.Sprintf("%s@%s", config.Repository, config.Reference)
+}
+
+/* The digest part of a repo@sha256:... reference */
+func digestOf(reference string) string {
+	if i := strings.Index(reference, "@"); i >= 0 {
+		return reference[i + 1:]
+	}
+	return reference
+}
+
+/* The repository part of an image name like registry:5000/repo:tag or repo@sha256:... */
+func repositoryOf(name string) string {
+	if i := strings.Index(name, "@"); i >= 0 {
+		return name[:i]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		return name[:i]
+	}
+	return name
+}
+
+/* The repository digest of the image for the repository, the first one if none matches */
+func repoDigest(image *DockerClient.Image, repository string) string {
+	for _, reference := range image.RepoDigests {
+		if strings.HasPrefix(reference, repository + "@") {
+			return digestOf(reference)
+		}
+	}
+	if len(image.RepoDigests) > 0 {
+		return digestOf(image.RepoDigests[0])
+	}
+	return ""
+}
+
+/* ContainerEngine is everything the client needs from the container runtime */
+type ContainerEngine interface {
+	InstallApp(name string, config model.VersionConfig) error
+	CreateApp(appId string, name string, appConf model.VersionConfig, labels map[string]string) error
+	StartApp(appId string) error
+	ListApps() ([]ManagedContainer, error)
+	QueryApp(appId string) bool
+	InspectApp(appId string) (AppInfo, error)
+	UsedHostPorts() (map[int]bool, error)
+	EnsureNetwork(name string) error
+	RemoveUnusedNetworks() ([]string, error)
+	EnsureVolume(app string, volume model.NamedVolume) error
+	RemoveVolumes(app string) ([]string, error)
+	VolumeUsage(app string) ([]model.VolumeUsage, error)
+	ImagesInUse() (map[string]bool, error)
+	RemoveImage(image string) error
+	PullProgress() map[string]model.PullProgress
+	ForgetPull(name string)
+	StopApp(appId string) error
+	RestartApp(appId string) error
+	SignalApp(appId string, signal string) error
+	ExecApp(appId string, command []string, timeout time.Duration) (ExecResult, error)
+	RemoveApp(appId string) bool
+	HostMetrics() model.Metric
+	HostCapacity() (model.HostCapacity, error)
+	AppMetrics(appId string) (model.Metric, error)
+	AppLogs(appId string) (string, string)
+}
+
+/* A bytes.Buffer that docker can write to while we drain it from another goroutine */
+type logBuffer struct {
+	mutex sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.Write(p)
+}
+
+/* Returns everything written so far and empties the buffer */
+func (b *logBuffer) Drain() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	ret := b.buffer.String()
+	b.buffer.Reset()
+	return ret
+}
+
 type LogItem struct{
-	StdOut *bytes.Buffer
-	StdErr *bytes.Buffer
+	StdOut *logBuffer
+	StdErr *logBuffer
 }
 
+/* DockerContainerEngine is safe for concurrent use, the metrics, logs and pulls maps are
+guarded by mutex */
 type DockerContainerEngine struct {
 	dockerCli *DockerClient.Client
 
+	mutex sync.Mutex
 	metrics map[string]*DockerMetrics
 	logs map[string]*LogItem
+	pulls map[string]*pullTracker
+	/* Bytes used by our local volumes keyed by docker name, measured every volumeMeasureInterval */
+	volumeSizes map[string]int64
 }
 
 func (c *DockerContainerEngine) Init() {
 	c.metrics = make(map[string]*DockerMetrics)
 	c.logs = make(map[string]*LogItem)
+	c.pulls = make(map[string]*pullTracker)
+	c.volumeSizes = make(map[string]int64)
 
 	var err error
 	c.dockerCli, err = DockerClient.NewClient("unix:///var/run/docker.sock")
 	if err != nil {
 		DockerLogger.Fatalf("Docker client could not be instantiated: %v", err)
 	}
+	go c.MeasureVolumes()
 }
 
 //func DockerCli() *DockerClient.Client {
@@ -72,9 +219,13 @@ func (c *DockerContainerEngine) Init() {
 //	return dockerCli
 //}
 
-func (c *DockerContainerEngine) InstallApp(name string, config model.VersionConfig) bool {
+func (c *DockerContainerEngine) InstallApp(name string, config model.VersionConfig) error {
 	DockerLogger.Infof("Installing docker app %s", name)
-	var buf bytes.Buffer
+	tracker := newPullTracker(ImageName(config.DockerConfig))
+	c.mutex.Lock()
+	c.pulls[name] = tracker
+	c.mutex.Unlock()
+
 	authOpt := DockerClient.AuthConfiguration{
 		Username: config.DockerConfig.Username,
 		Password: config.DockerConfig.Password,
@@ -84,25 +235,55 @@ func (c *DockerContainerEngine) InstallApp(name string, config model.VersionConf
 	imageOpt := DockerClient.PullImageOptions{
 		Repository: config.DockerConfig.Repository,
 		Tag: config.DockerConfig.Tag,
-		OutputStream: &buf,
+		OutputStream: tracker,
+		RawJSONStream: true,
+	}
+	/* The registry API takes a digest wherever it takes a tag */
+	if config.DockerConfig.Reference != "" {
+		imageOpt.Tag = digestOf(config.DockerConfig.Reference)
 	}
 	err := c.dockerCli.PullImage(imageOpt, authOpt)
+	tracker.finish(err)
 	if err != nil {
 		DockerLogger.Errorf("Install of app %s failed: %s", name, err)
-		return false
+		return err
+	}
+
+	if config.DockerConfig.Reference != "" {
+		image, err := c.dockerCli.InspectImage(ImageName(config.DockerConfig))
+		if err != nil {
+			DockerLogger.Errorf("Install of app %s failed, pulled image not found: %s", name, err)
+			return err
+		}
+		want := digestOf(config.DockerConfig.Reference)
+		found := false
+		for _, reference := range image.RepoDigests {
+			found = found || digestOf(reference) == want
+		}
+		if !found {
+			DockerLogger.Errorf("Install of app %s failed, image %s has digests %v", name, image.ID, image.RepoDigests)
+			return fmt.Errorf("Pulled image %s does not match digest %s", image.ID, want)
+		}
 	}
 
 	DockerLogger.Infof("Install of app %s successful", name)
-	return true
+	return nil
 }
 
 
-func (c *DockerContainerEngine) RunApp(appId string, name string, appConf model.VersionConfig) bool {
+/* Creates the container for the app, it still has to be started with StartApp */
+func (c *DockerContainerEngine) CreateApp(appId string, name string, appConf model.VersionConfig, labels map[string]string) error {
 	bindings := make(map[DockerClient.Port][]DockerClient.PortBinding)
 	ports := make(map[DockerClient.Port]struct{})
+	publishAll := true
 	for _, v := range appConf.PortMappings {
-		bindings[DockerClient.Port(v.ContainerPort)] = []DockerClient.PortBinding{DockerClient.PortBinding{HostPort: v.HostPort}}
 		ports[DockerClient.Port(v.ContainerPort)] = struct{}{}
+		if v.Internal {
+			/* Publishing all ports would bind the internal ones to random host ports */
+			publishAll = false
+			continue
+		}
+		bindings[DockerClient.Port(v.ContainerPort)] = []DockerClient.PortBinding{DockerClient.PortBinding{HostPort: v.HostPort}}
 	}
 	DockerLogger.Warnf("Bindinds are %+v", bindings)
 
@@ -111,37 +292,82 @@ func (c *DockerContainerEngine) RunApp(appId string, name string, appConf model.
 		env.Set(item.Key, item.Value)
 	}
 
-	/* Handle Files */
-	os.Mkdir("/tmp/" + appId, 600)
-	for _, file := range appConf.Files {
-		fp, err := os.Create("/tmp/" + appId + file.HostPath)
-		if err == nil {
-			fp.WriteString(file.Base64FileContents)
-			fp.Close()
-		}
-	}
+	/* Files are written by the client and arrive here as volume mappings */
+	mounts := volumeBinds(name, appConf.VolumeMappings)
 
-	mounts := make([]string, 1)
-	mounts[0] = "/tmp/" + appId + ":/orcatmp"
+	/* Our labels go last so they win over the labels of the config */
+	containerLabels := make(map[string]string)
+	for key, value := range appConf.Labels {
+		containerLabels[key] = value
+	}
+	for key, value := range labels {
+		containerLabels[key] = value
+	}
 
-	hostConfig := DockerClient.HostConfig{PortBindings: bindings, PublishAllPorts:true, Binds:mounts}
-	config := DockerClient.Config{AttachStdout: true, AttachStdin: true, Image: fmt.Sprintf("%s:%s", appConf.DockerConfig.Repository, appConf.DockerConfig.Tag), ExposedPorts:ports, Env:env,}
+	hostConfig := DockerClient.HostConfig{PortBindings: bindings, PublishAllPorts:publishAll, Binds:mounts, ExtraHosts: appConf.ExtraHosts, DNS: appConf.Dns}
+	if appConf.RestartPolicy != nil {
+		hostConfig.RestartPolicy = DockerClient.RestartPolicy{Name: appConf.RestartPolicy.Name, MaximumRetryCount: appConf.RestartPolicy.MaximumRetryCount}
+	}
+	applyResources(&hostConfig, appConf.Resources)
+	config := DockerClient.Config{
+		AttachStdout: true,
+		AttachStdin: true,
+		Image: ImageName(appConf.DockerConfig),
+		ExposedPorts: ports,
+		Env: env,
+		Labels: containerLabels,
+		Cmd: appConf.Command,
+		Entrypoint: appConf.Entrypoint,
+		User: appConf.User,
+		WorkingDir: appConf.WorkingDir,
+		Hostname: appConf.Hostname,
+	}
 	opts := DockerClient.CreateContainerOptions{Name: string(appId), Config: &config, HostConfig:&hostConfig}
-	container, containerErr :=c.dockerCli.CreateContainer(opts)
+
+	/* Docker only takes one network when creating the container, the others are connected afterwards */
+	if len(appConf.Networks) > 0 {
+		first := appConf.Networks[0]
+		hostConfig.NetworkMode = first.Name
+		opts.NetworkingConfig = &DockerClient.NetworkingConfig{EndpointsConfig: map[string]*DockerClient.EndpointConfig{
+			first.Name: &DockerClient.EndpointConfig{Aliases: networkAliases(name, first)},
+		}}
+	}
+
+	_, containerErr :=c.dockerCli.CreateContainer(opts)
 	if containerErr != nil {
-		DockerLogger.Errorf("Running docker app %s with error %s", appId, containerErr)
-		return false
+		DockerLogger.Errorf("Creating docker app %s with error %s", appId, containerErr)
+		return containerErr
+	}
+	if err := c.connectNetworks(appId, name, appConf); err != nil {
+		return err
 	}
+	DockerLogger.Infof("Creating docker app %s - %s successful", appId, name)
+	return nil
+}
+
 
-	err := c.dockerCli.StartContainer(container.ID, &hostConfig)
+/* Lists all containers, running or not, that carry our labels */
+func (c *DockerContainerEngine) ListApps() ([]ManagedContainer, error) {
+	opts := DockerClient.ListContainersOptions{All: true, Filters: map[string][]string{"label": {LabelApp}}}
+	containers, err := c.dockerCli.ListContainers(opts)
 	if err != nil {
-		DockerLogger.Errorf("Running docker app %s with error %s", appId, err)
-		return false
+		DockerLogger.Errorf("Listing docker apps failed: %s", err)
+		return nil, err
 	}
-	DockerLogger.Infof("Running docker app %s - %s successful", appId)
-	return true
-}
 
+	ret := make([]ManagedContainer, 0)
+	for _, container := range containers {
+		if len(container.Names) == 0 {
+			continue
+		}
+		ret = append(ret, ManagedContainer{
+			DockerAppId: strings.TrimPrefix(container.Names[0], "/"),
+			Running: container.State == "running",
+			Labels: container.Labels,
+		})
+	}
+	return ret, nil
+}
 
 func (c *DockerContainerEngine) QueryApp(appId string) bool {
 	DockerLogger.Debugf("Query docker app %s", appId)
@@ -154,8 +380,238 @@ func (c *DockerContainerEngine) QueryApp(appId string) bool {
 	return resp.State.Running
 }
 
-func (c *DockerContainerEngine) StopApp(appId string) bool {
+/* Inspects the container, unlike QueryApp errors are reported */
+func (c *DockerContainerEngine) InspectApp(appId string) (AppInfo, error) {
+	resp, err := c.dockerCli.InspectContainer(appId)
+	if err != nil {
+		DockerLogger.Errorf("Inspecting docker app %s failed: %s", appId, err)
+		return AppInfo{}, err
+	}
+
+	info := AppInfo{Running: resp.State.Running, ExitCode: resp.State.ExitCode, ImageId: resp.Image, Ports: make([]model.PortMapping, 0)}
+	info.Resources = resourcesOf(resp.HostConfig)
+	if image, err := c.dockerCli.InspectImage(resp.Image); err == nil && resp.Config != nil {
+		info.Digest = repoDigest(image, repositoryOf(resp.Config.Image))
+	}
+	if resp.State.Health.Status != "" {
+		info.Health = dockerHealth(resp.State.Health)
+	}
+	if resp.NetworkSettings != nil {
+		info.IP = resp.NetworkSettings.IPAddress
+		if info.IP == "" {
+			networkMode := ""
+			if resp.HostConfig != nil {
+				networkMode = resp.HostConfig.NetworkMode
+			}
+			info.IP = firstNetworkIP(networkMode, resp.NetworkSettings.Networks)
+		}
+		for port, bindings := range resp.NetworkSettings.Ports {
+			for _, binding := range bindings {
+				info.Ports = append(info.Ports, model.PortMapping{HostPort: binding.HostPort, ContainerPort: string(port)})
+			}
+		}
+	}
+	return info, nil
+}
+
+/* The address on the network the container was created on, which is the first network
+of its config. The others are tried by name so the choice does not change between calls. */
+func firstNetworkIP(networkMode string, networks map[string]DockerClient.ContainerNetwork) string {
+	if network, ok := networks[networkMode]; ok && network.IPAddress != "" {
+		return network.IPAddress
+	}
+	names := make([]string, 0, len(networks))
+	for name := range networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if networks[name].IPAddress != "" {
+			return networks[name].IPAddress
+		}
+	}
+	return ""
+}
+
+func dockerHealth(health DockerClient.Health) *model.DockerHealth {
+	ret := &model.DockerHealth{Status: health.Status, FailingStreak: health.FailingStreak, Log: make([]model.CheckResult, 0)}
+	for _, entry := range health.Log {
+		result := model.CheckResult{
+			Type: "docker",
+			Passed: entry.ExitCode == 0,
+			Output: entry.Output,
+			Time: entry.Start,
+			Latency: int64(entry.End.Sub(entry.Start) / time.Millisecond),
+		}
+		if !result.Passed {
+			result.Error = fmt.Sprintf("Exited with %d", entry.ExitCode)
+		}
+		ret.Log = append(ret.Log, result)
+	}
+	return ret
+}
+
+/* The latest pull of each app, keyed by app name */
+func (c *DockerContainerEngine) PullProgress() map[string]model.PullProgress {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	ret := make(map[string]model.PullProgress)
+	now := time.Now()
+	for name, tracker := range c.pulls {
+		if tracker.expired(now) {
+			delete(c.pulls, name)
+			continue
+		}
+		ret[name] = tracker.snapshot()
+	}
+	return ret
+}
+
+/* Stops reporting the pulls of a deleted app */
+func (c *DockerContainerEngine) ForgetPull(name string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.pulls, name)
+}
+
+/* Names and ids of the images of all containers, running or not, ours or not */
+func (c *DockerContainerEngine) ImagesInUse() (map[string]bool, error) {
+	containers, err := c.dockerCli.ListContainers(DockerClient.ListContainersOptions{All: true})
+	if err != nil {
+		DockerLogger.Errorf("Listing docker images in use failed: %s", err)
+		return nil, err
+	}
+
+	used := make(map[string]bool)
+	for _, container := range containers {
+		used[container.Image] = true
+		resp, err := c.dockerCli.InspectContainer(container.ID)
+		if err != nil {
+			return nil, err
+		}
+		used[resp.Image] = true
+	}
+	return used, nil
+}
+
+/* Removes the image by name, the image itself goes once no other name refers to it */
+func (c *DockerContainerEngine) RemoveImage(image string) error {
+	DockerLogger.Infof("Removing docker image %s", image)
+	err := c.dockerCli.RemoveImage(image)
+	if err != nil && err != DockerClient.ErrNoSuchImage {
+		DockerLogger.Errorf("Removing docker image %s - failed: %s", image, err)
+		return err
+	}
+	return nil
+}
+
+/* Host ports published by any running container, ours or not */
+func (c *DockerContainerEngine) UsedHostPorts() (map[int]bool, error) {
+	containers, err := c.dockerCli.ListContainers(DockerClient.ListContainersOptions{})
+	if err != nil {
+		DockerLogger.Errorf("Listing docker ports failed: %s", err)
+		return nil, err
+	}
+
+	used := make(map[int]bool)
+	for _, container := range containers {
+		for _, port := range container.Ports {
+			if port.PublicPort != 0 {
+				used[int(port.PublicPort)] = true
+			}
+		}
+	}
+	return used, nil
+}
+
+/* Stops the container but keeps it around so it can be started again */
+func (c *DockerContainerEngine) StopApp(appId string) error {
 	DockerLogger.Infof("Stopping docker app %s", appId)
+	err := c.dockerCli.StopContainer(appId, 10)
+	if err != nil {
+		DockerLogger.Errorf("Stopping docker app %s - failed: %s", appId, err)
+		return err
+	}
+	DockerLogger.Infof("Stopping docker app %s - successful", appId)
+	return nil
+}
+
+func (c *DockerContainerEngine) RestartApp(appId string) error {
+	DockerLogger.Infof("Restarting docker app %s", appId)
+	err := c.dockerCli.RestartContainer(appId, 10)
+	if err != nil {
+		DockerLogger.Errorf("Restarting docker app %s - failed: %s", appId, err)
+		return err
+	}
+	DockerLogger.Infof("Restarting docker app %s - successful", appId)
+	return nil
+}
+
+/* Sends a signal, given by name (SIGHUP or HUP) or number, to the main process of the container */
+func (c *DockerContainerEngine) SignalApp(appId string, signal string) error {
+	sig, err := ParseSignal(signal)
+	if err != nil {
+		return err
+	}
+
+	DockerLogger.Infof("Sending %s to docker app %s", signal, appId)
+	err = c.dockerCli.KillContainer(DockerClient.KillContainerOptions{ID: appId, Signal: sig})
+	if err != nil {
+		DockerLogger.Errorf("Sending %s to docker app %s - failed: %s", signal, appId, err)
+		return err
+	}
+	return nil
+}
+
+/* Runs a command inside the running container and waits at most timeout for it to exit */
+func (c *DockerContainerEngine) ExecApp(appId string, command []string, timeout time.Duration) (ExecResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	exec, err := c.dockerCli.CreateExec(DockerClient.CreateExecOptions{
+		Container: appId,
+		Cmd: command,
+		AttachStdout: true,
+		AttachStderr: true,
+		Context: ctx,
+	})
+	if err != nil {
+		DockerLogger.Debugf("Creating exec in docker app %s failed: %s", appId, err)
+		return ExecResult{}, err
+	}
+
+	var output bytes.Buffer
+	err = c.dockerCli.StartExec(exec.ID, DockerClient.StartExecOptions{OutputStream: &output, ErrorStream: &output, Context: ctx})
+	if err != nil {
+		DockerLogger.Debugf("Running exec in docker app %s failed: %s", appId, err)
+		return ExecResult{Output: output.String()}, err
+	}
+
+	inspect, err := c.dockerCli.InspectExec(exec.ID)
+	if err != nil {
+		return ExecResult{Output: output.String()}, err
+	}
+	if inspect.Running {
+		return ExecResult{Output: output.String()}, fmt.Errorf("Command %v did not exit within %s", command, timeout)
+	}
+	return ExecResult{ExitCode: inspect.ExitCode, Output: output.String()}, nil
+}
+
+func (c *DockerContainerEngine) StartApp(appId string) error {
+	DockerLogger.Infof("Starting docker app %s", appId)
+	err := c.dockerCli.StartContainer(appId, nil)
+	if err != nil {
+		DockerLogger.Errorf("Starting docker app %s - failed: %s", appId, err)
+		return err
+	}
+	DockerLogger.Infof("Starting docker app %s - successful", appId)
+	return nil
+}
+
+/* Stops and removes the container */
+func (c *DockerContainerEngine) RemoveApp(appId string) bool {
+	DockerLogger.Infof("Removing docker app %s", appId)
 	err := c.dockerCli.StopContainer(fmt.Sprintf("%s", appId), 0)
 	fail := false
 	if err != nil {
@@ -164,14 +620,15 @@ func (c *DockerContainerEngine) StopApp(appId string) bool {
 	}
 	opts := DockerClient.RemoveContainerOptions{ID: string(appId)}
 	err = c.dockerCli.RemoveContainer(opts)
+	c.forget(appId)
 	if err != nil {
-		DockerLogger.Infof("Stopping docker app %s - %s", appId, err)
+		DockerLogger.Infof("Removing docker app %s - %s", appId, err)
 		fail = true
 	}
 	if fail {
 		return false
 	}
-	DockerLogger.Infof("Stopping docker app %s - successful", appId)
+	DockerLogger.Infof("Removing docker app %s - successful", appId)
 	return true
 }
 
@@ -202,8 +659,9 @@ func (eng *DockerContainerEngine) HostMetrics() model.Metric {
 }
 
 func (c *DockerContainerEngine) AppMetrics(appId string) (model.Metric, error) {
-	DockerLogger.Debugf("Getting AppMetrics for app %s %s:%d", appId)
+	DockerLogger.Debugf("Getting AppMetrics for app %s", appId)
 
+	c.mutex.Lock()
 	if _, ok := c.metrics[appId]; !ok {
 		metricsItem := &DockerMetrics{
 			done: make (chan bool),
@@ -220,6 +678,7 @@ func (c *DockerContainerEngine) AppMetrics(appId string) (model.Metric, error) {
 	}
 
 	entry := c.metrics[appId]
+	c.mutex.Unlock()
 
 	var resultStats []*DockerClient.Stats
 	count := 0
@@ -240,24 +699,34 @@ func (c *DockerContainerEngine) AppMetrics(appId string) (model.Metric, error) {
 }
 
 func (engine *DockerContainerEngine) AppLogs(appId string) (string, string) {
+	engine.mutex.Lock()
 	if _, ok := engine.logs[appId]; !ok {
-		fmt.Println(fmt.Sprintf("starting logs for %s", appId))
+		DockerLogger.Debugf("Starting logs for %s", appId)
 		engine.logs[appId] = &LogItem{
-			StdOut: new(bytes.Buffer),
-			StdErr: new(bytes.Buffer),
+			StdOut: new(logBuffer),
+			StdErr: new(logBuffer),
 		}
 		logs := engine.logs[appId]
 		go func() {
 			engine.dockerCli.Logs(DockerClient.LogsOptions{Container: string(appId), OutputStream: logs.StdOut, ErrorStream: logs.StdErr, Stderr: true, Stdout: true, Follow: true})
 		}()
 	}
-
 	logs := engine.logs[appId]
-	outLogs := logs.StdOut.String()
-	errLogs := logs.StdErr.String()
-	logs.StdOut.Reset()
-	logs.StdErr.Reset()
-	return outLogs, errLogs
+	engine.mutex.Unlock()
+
+	return logs.StdOut.Drain(), logs.StdErr.Drain()
+}
+
+/* Drops the metrics and logs streams of a removed container */
+func (engine *DockerContainerEngine) forget(appId string) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	if metrics, ok := engine.metrics[appId]; ok {
+		close(metrics.done)
+		delete(engine.metrics, appId)
+	}
+	delete(engine.logs, appId)
 }
 
 func parseDockerStats(stat0 *DockerClient.Stats, stat1 *DockerClient.Stats) (model.Metric, error) {
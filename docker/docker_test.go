@@ -0,0 +1,180 @@
+package docker
+
+import (
+	DockerClient "github.com/fsouza/go-dockerclient"
+	"errors"
+	"orcahostd/model"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLogBuffer_ConcurrentWriteAndDrain(t *testing.T) {
+	buffer := &logBuffer{}
+	var writers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		writers.Add(1)
+		go func() {
+			defer writers.Done()
+			for j := 0; j < 100; j++ {
+				buffer.Write([]byte("x\n"))
+			}
+		}()
+	}
+
+	drained := ""
+	done := make(chan struct{})
+	go func() {
+		writers.Wait()
+		close(done)
+	}()
+	for {
+		drained += buffer.Drain()
+		select {
+		case <-done:
+			drained += buffer.Drain()
+			if strings.Count(drained, "x\n") != 400 {
+				t.Error(len(drained))
+			}
+			return
+		default:
+		}
+	}
+}
+
+func TestParseSignal(t *testing.T) {
+	for _, name := range []string{"SIGHUP", "HUP", "hup", "1"} {
+		if sig, err := ParseSignal(name); err != nil || sig != 1 {
+			t.Error(name, sig, err)
+		}
+	}
+	if _, err := ParseSignal("SIGNOPE"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestImageName(t *testing.T) {
+	cases := []struct {
+		config model.DockerConfig
+		want   string
+	}{
+		{model.DockerConfig{Repository: "orca/app", Tag: "1.0"}, "orca/app:1.0"},
+		{model.DockerConfig{Repository: "orca/app", Tag: "latest", Reference: "sha256:abc"}, "orca/app@sha256:abc"},
+		{model.DockerConfig{Repository: "orca/app", Reference: "other/app@sha256:abc"}, "other/app@sha256:abc"},
+	}
+	for _, c := range cases {
+		if got := ImageName(c.config); got != c.want {
+			t.Error(c.config, got)
+		}
+	}
+}
+
+func TestRepositoryOf(t *testing.T) {
+	cases := map[string]string{
+		"orca/app:1.0": "orca/app",
+		"registry:5000/orca/app": "registry:5000/orca/app",
+		"registry:5000/orca/app:1.0": "registry:5000/orca/app",
+		"orca/app@sha256:abc": "orca/app",
+	}
+	for name, want := range cases {
+		if got := repositoryOf(name); got != want {
+			t.Error(name, got)
+		}
+	}
+}
+
+func TestPullTracker_FollowsLayers(t *testing.T) {
+	stream := `{"status":"Pulling from orca/app","id":"1.0"}
+{"status":"Pulling fs layer","progressDetail":{},"id":"a"}
+{"status":"Already exists","progressDetail":{},"id":"b"}
+{"status":"Downloading","progressDetail":{"current":50,"total":200},"id":"a"}
+{"status":"Pulling fs layer","progressDetail":{},"id":"c"}
+{"status":"Downloading","progressDetail":{"current":10,"total":100},"id":"c"}
+`
+	tracker := newPullTracker("orca/app:1.0")
+	/* Docker does not write whole lines at a time */
+	for i := 0; i < len(stream); i += 7 {
+		end := i + 7
+		if end > len(stream) {
+			end = len(stream)
+		}
+		tracker.Write([]byte(stream[i:end]))
+	}
+
+	progress := tracker.snapshot()
+	if progress.Layers != 3 || progress.LayersDone != 1 || progress.BytesDone != 60 || progress.BytesTotal != 300 || progress.Status != model.PullPulling {
+		t.Error(progress)
+	}
+
+	tracker.Write([]byte(`{"status":"Pull complete","progressDetail":{},"id":"a"}` + "\n" + `{"error":"unauthorized"}` + "\n"))
+	tracker.finish(errors.New("unauthorized"))
+	progress = tracker.snapshot()
+	if progress.LayersDone != 2 || progress.BytesDone != 210 || progress.Status != model.PullFailed || progress.Error != "unauthorized" {
+		t.Error(progress)
+	}
+}
+
+func TestResources_RoundTrip(t *testing.T) {
+	resources := model.Resources{
+		Memory: 1 << 28,
+		MemorySwap: -1,
+		CpuQuota: 50000,
+		CpusetCpus: "0",
+		BlkioWeight: 500,
+		Ulimits: []model.Ulimit{{Name: "nofile", Soft: 1024, Hard: 2048}},
+	}
+	hostConfig := DockerClient.HostConfig{}
+	applyResources(&hostConfig, resources)
+	if hostConfig.Memory != resources.Memory || hostConfig.CPUSetCPUs != "0" || len(hostConfig.Ulimits) != 1 {
+		t.Error(hostConfig)
+	}
+	if got := resourcesOf(&hostConfig); got.BlkioWeight != 500 || got.MemorySwap != -1 || got.Ulimits[0].Hard != 2048 {
+		t.Error(got)
+	}
+}
+
+func TestNetworkAliases_AppNameFirst(t *testing.T) {
+	aliases := networkAliases("app1", model.NetworkAttachment{Name: "backend", Aliases: []string{"api", "app1"}})
+	if len(aliases) != 2 || aliases[0] != "app1" || aliases[1] != "api" {
+		t.Error(aliases)
+	}
+}
+
+func TestVolumeBinds(t *testing.T) {
+	binds := volumeBinds("app1", []model.VolumeMapping{
+		{HostPath: "/srv/static", ContainerPath: "/static", ReadOnly: true},
+		{Volume: "data", ContainerPath: "/data"},
+	})
+	if len(binds) != 2 || binds[0] != "/srv/static:/static:ro" || binds[1] != "app1_data:/data" {
+		t.Error(binds)
+	}
+}
+
+func TestPullTracker_ExpiresWhenFinished(t *testing.T) {
+	tracker := newPullTracker("orca/app:1.0")
+	later := time.Now().Add(2 * pullRetention)
+	if tracker.expired(later) {
+		t.Error("running pull expired")
+	}
+	tracker.finish(nil)
+	if tracker.expired(time.Now()) || !tracker.expired(later) {
+		t.Error(tracker.snapshot())
+	}
+}
+
+func TestFirstNetworkIP_PrefersNetworkMode(t *testing.T) {
+	networks := map[string]DockerClient.ContainerNetwork{
+		"frontend": {IPAddress: "172.20.0.2"},
+		"backend": {IPAddress: "172.21.0.2"},
+		"empty": {},
+	}
+	for i := 0; i < 10; i++ {
+		if ip := firstNetworkIP("frontend", networks); ip != "172.20.0.2" {
+			t.Fatal(ip)
+		}
+		if ip := firstNetworkIP("", networks); ip != "172.21.0.2" {
+			t.Fatal(ip)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Copyright Alex Mack and Michael Lawson (michael@sphinix.com)
+This file is part of Orca.
+
+Orca is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Orca is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Orca.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package docker
+
+import (
+	DockerClient "github.com/fsouza/go-dockerclient"
+	"orcahostd/model"
+)
+
+/* Put on the networks we create, only those are ever removed */
+const LabelManagedNetwork = "orca.managed"
+
+/* Networks we created, keyed by name */
+func (c *DockerContainerEngine) managedNetworks() (map[string]DockerClient.Network, error) {
+	networks, err := c.dockerCli.FilteredListNetworks(DockerClient.NetworkFilterOpts{"label": {LabelManagedNetwork: true}})
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string]DockerClient.Network)
+	for _, network := range networks {
+		ret[network.Name] = network
+	}
+	return ret, nil
+}
+
+/* Creates a bridge network unless one with the name exists, networks created by someone
+else are used as they are */
+func (c *DockerContainerEngine) EnsureNetwork(name string) error {
+	if _, err := c.dockerCli.NetworkInfo(name); err == nil {
+		return nil
+	}
+
+	DockerLogger.Infof("Creating docker network %s", name)
+	_, err := c.dockerCli.CreateNetwork(DockerClient.CreateNetworkOptions{
+		Name: name,
+		Driver: "bridge",
+		CheckDuplicate: true,
+		Labels: map[string]string{LabelManagedNetwork: "true"},
+	})
+	if err != nil {
+		DockerLogger.Errorf("Creating docker network %s - failed: %s", name, err)
+		return err
+	}
+	return nil
+}
+
+/* Removes the networks we created that no container, running or not, is attached to anymore */
+func (c *DockerContainerEngine) RemoveUnusedNetworks() ([]string, error) {
+	networks, err := c.managedNetworks()
+	if err != nil {
+		DockerLogger.Errorf("Listing docker networks failed: %s", err)
+		return nil, err
+	}
+
+	removed := make([]string, 0)
+	for name, network := range networks {
+		/* NetworkInfo only lists running containers, stopped ones still need the network to start again */
+		containers, err := c.dockerCli.ListContainers(DockerClient.ListContainersOptions{All: true, Filters: map[string][]string{"network": {network.ID}}})
+		if err != nil || len(containers) > 0 {
+			continue
+		}
+		DockerLogger.Infof("Removing unused docker network %s", name)
+		if err := c.dockerCli.RemoveNetwork(network.ID); err != nil {
+			DockerLogger.Errorf("Removing docker network %s - failed: %s", name, err)
+			continue
+		}
+		removed = append(removed, name)
+	}
+	return removed, nil
+}
+
+/* The DNS names of an instance of the app on a network */
+func networkAliases(name string, network model.NetworkAttachment) []string {
+	aliases := []string{name}
+	for _, alias := range network.Aliases {
+		if alias != name {
+			aliases = append(aliases, alias)
+		}
+	}
+	return aliases
+}
+
+/* Attaches the created container to all but the first network of the config, the first
+one is given when creating the container */
+func (c *DockerContainerEngine) connectNetworks(appId string, name string, appConf model.VersionConfig) error {
+	for i, network := range appConf.Networks {
+		if i == 0 {
+			continue
+		}
+		err := c.dockerCli.ConnectNetwork(network.Name, DockerClient.NetworkConnectionOptions{
+			Container: appId,
+			EndpointConfig: &DockerClient.EndpointConfig{Aliases: networkAliases(name, network)},
+		})
+		if err != nil {
+			DockerLogger.Errorf("Connecting docker app %s to network %s - failed: %s", appId, network.Name, err)
+			return err
+		}
+	}
+	return nil
+}
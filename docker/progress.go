@@ -0,0 +1,158 @@
+/*
+Copyright Alex Mack and Michael Lawson (michael@sphinix.com)
+This file is part of Orca.
+
+Orca is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Orca is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Orca.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package docker
+
+import (
+	"bytes"
+	"encoding/json"
+	"orcahostd/model"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* Finished pulls are reported for this long, then forgotten */
+const pullRetention = time.Hour
+
+/* One line of the JSON stream docker writes while pulling */
+type pullMessage struct {
+	Status         string
+	ID             string `json:"id"`
+	Error          string `json:"error"`
+	ProgressDetail struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+}
+
+type layerProgress struct {
+	current int64
+	total   int64
+	done    bool
+}
+
+/* pullTracker is the output stream of a pull, it follows the progress of every layer */
+type pullTracker struct {
+	mutex    sync.Mutex
+	pending  []byte
+	order    []string
+	layers   map[string]*layerProgress
+	progress model.PullProgress
+}
+
+func newPullTracker(image string) *pullTracker {
+	now := time.Now()
+	return &pullTracker{
+		layers: make(map[string]*layerProgress),
+		progress: model.PullProgress{Image: image, Status: model.PullPulling, Started: now, Updated: now},
+	}
+}
+
+/* Whether the pull finished more than pullRetention before now */
+func (tracker *pullTracker) expired(now time.Time) bool {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	return tracker.progress.Status != model.PullPulling && now.Sub(tracker.progress.Updated) > pullRetention
+}
+
+func (tracker *pullTracker) Write(p []byte) (int, error) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	tracker.pending = append(tracker.pending, p...)
+	for {
+		end := bytes.IndexByte(tracker.pending, '\n')
+		if end < 0 {
+			break
+		}
+		line := bytes.TrimSpace(tracker.pending[:end])
+		tracker.pending = tracker.pending[end + 1:]
+
+		message := pullMessage{}
+		if len(line) > 0 && json.Unmarshal(line, &message) == nil {
+			tracker.handle(message)
+		}
+	}
+	return len(p), nil
+}
+
+func (tracker *pullTracker) handle(message pullMessage) {
+	tracker.progress.Updated = time.Now()
+	if message.Error != "" {
+		tracker.progress.Error = message.Error
+		return
+	}
+	/* Lines without a layer id, or naming the tag being pulled, are about the whole image */
+	if message.ID == "" || strings.HasPrefix(message.Status, "Pulling from") {
+		return
+	}
+
+	layer, ok := tracker.layers[message.ID]
+	if !ok {
+		layer = &layerProgress{}
+		tracker.layers[message.ID] = layer
+		tracker.order = append(tracker.order, message.ID)
+	}
+	switch message.Status {
+	case "Downloading":
+		layer.current = message.ProgressDetail.Current
+		if message.ProgressDetail.Total > 0 {
+			layer.total = message.ProgressDetail.Total
+		}
+	case "Download complete", "Verifying Checksum":
+		layer.current = layer.total
+	case "Pull complete", "Already exists":
+		layer.current = layer.total
+		layer.done = true
+	}
+
+	tracker.progress.Layers = len(tracker.layers)
+	tracker.progress.LayersDone = 0
+	tracker.progress.BytesDone = 0
+	tracker.progress.BytesTotal = 0
+	for _, id := range tracker.order {
+		layer := tracker.layers[id]
+		if layer.done {
+			tracker.progress.LayersDone++
+		}
+		tracker.progress.BytesDone += layer.current
+		tracker.progress.BytesTotal += layer.total
+	}
+}
+
+/* Marks the pull as finished, err is the error the pull returned */
+func (tracker *pullTracker) finish(err error) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	tracker.progress.Updated = time.Now()
+	if err != nil {
+		tracker.progress.Status = model.PullFailed
+		tracker.progress.Error = err.Error()
+		return
+	}
+	tracker.progress.Status = model.PullDone
+}
+
+func (tracker *pullTracker) snapshot() model.PullProgress {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	return tracker.progress
+}
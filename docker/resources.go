@@ -0,0 +1,73 @@
+/*
+Copyright Alex Mack and Michael Lawson (michael@sphinix.com)
+This file is part of Orca.
+
+Orca is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Orca is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Orca.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package docker
+
+import (
+	DockerClient "github.com/fsouza/go-dockerclient"
+	"github.com/shirou/gopsutil/mem"
+	"orcahostd/model"
+	"runtime"
+)
+
+/* Sets the limits of the resources on the host config of a container */
+func applyResources(hostConfig *DockerClient.HostConfig, resources model.Resources) {
+	hostConfig.Memory = resources.Memory
+	hostConfig.MemoryReservation = resources.MemoryReservation
+	hostConfig.MemorySwap = resources.MemorySwap
+	hostConfig.CPUShares = resources.CpuShares
+	hostConfig.CPUQuota = resources.CpuQuota
+	hostConfig.CPUPeriod = resources.CpuPeriod
+	hostConfig.CPUSetCPUs = resources.CpusetCpus
+	hostConfig.PidsLimit = resources.PidsLimit
+	hostConfig.BlkioWeight = resources.BlkioWeight
+	for _, ulimit := range resources.Ulimits {
+		hostConfig.Ulimits = append(hostConfig.Ulimits, DockerClient.ULimit{Name: ulimit.Name, Soft: ulimit.Soft, Hard: ulimit.Hard})
+	}
+}
+
+/* The limits set on the host config of a container */
+func resourcesOf(hostConfig *DockerClient.HostConfig) model.Resources {
+	if hostConfig == nil {
+		return model.Resources{}
+	}
+	resources := model.Resources{
+		Memory: hostConfig.Memory,
+		MemoryReservation: hostConfig.MemoryReservation,
+		MemorySwap: hostConfig.MemorySwap,
+		CpuShares: hostConfig.CPUShares,
+		CpuQuota: hostConfig.CPUQuota,
+		CpuPeriod: hostConfig.CPUPeriod,
+		CpusetCpus: hostConfig.CPUSetCPUs,
+		PidsLimit: hostConfig.PidsLimit,
+		BlkioWeight: hostConfig.BlkioWeight,
+	}
+	for _, ulimit := range hostConfig.Ulimits {
+		resources.Ulimits = append(resources.Ulimits, model.Ulimit{Name: ulimit.Name, Soft: ulimit.Soft, Hard: ulimit.Hard})
+	}
+	return resources
+}
+
+func (c *DockerContainerEngine) HostCapacity() (model.HostCapacity, error) {
+	m, err := mem.VirtualMemory()
+	if err != nil {
+		return model.HostCapacity{}, err
+	}
+	return model.HostCapacity{Memory: int64(m.Total), Cpus: runtime.NumCPU()}, nil
+}
@@ -0,0 +1,53 @@
+/*
+Copyright Alex Mack and Michael Lawson (michael@sphinix.com)
+This file is part of Orca.
+
+Orca is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Orca is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Orca.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package docker
+
+import (
+	DockerClient "github.com/fsouza/go-dockerclient"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var signals = map[string]DockerClient.Signal{
+	"HUP": DockerClient.SIGHUP,
+	"INT": DockerClient.SIGINT,
+	"QUIT": DockerClient.SIGQUIT,
+	"KILL": DockerClient.SIGKILL,
+	"USR1": DockerClient.SIGUSR1,
+	"USR2": DockerClient.SIGUSR2,
+	"TERM": DockerClient.SIGTERM,
+	"CONT": DockerClient.SIGCONT,
+	"STOP": DockerClient.SIGSTOP,
+	"WINCH": DockerClient.SIGWINCH,
+}
+
+/* Turns SIGHUP, HUP, hup or 1 into the signal to send */
+func ParseSignal(signal string) (DockerClient.Signal, error) {
+	if number, err := strconv.Atoi(signal); err == nil && number > 0 && number < 65 {
+		return DockerClient.Signal(number), nil
+	}
+
+	name := strings.TrimPrefix(strings.ToUpper(signal), "SIG")
+	if sig, ok := signals[name]; ok {
+		return sig, nil
+	}
+	return 0, fmt.Errorf("Unknown signal %s", signal)
+}
@@ -0,0 +1,202 @@
+/*
+Copyright Alex Mack and Michael Lawson (michael@sphinix.com)
+This file is part of Orca.
+
+Orca is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Orca is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Orca.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package docker
+
+import (
+	DockerClient "github.com/fsouza/go-dockerclient"
+	"fmt"
+	"orcahostd/model"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+/* Put on the named volumes we create next to LabelApp */
+const LabelVolume = "orca.volume"
+
+/* Walking a volume reads every directory in it, so sizes are only measured this often */
+const volumeMeasureInterval = 10 * time.Minute
+
+/* The docker name of a named volume, volumes are scoped to their app. Names can contain
+the separator, so the labels tell whose volume it is. */
+func VolumeName(app string, volume string) string {
+	return app + "_" + volume
+}
+
+/* Creates the named volume of the app unless it exists, an existing volume is used as it
+is so its data survives upgrades. A volume of the same name that belongs to another app,
+or was not created by us, is never reused. */
+func (c *DockerContainerEngine) EnsureVolume(app string, volume model.NamedVolume) error {
+	name := VolumeName(app, volume.Name)
+	if existing, err := c.dockerCli.InspectVolume(name); err == nil {
+		if existing.Labels[LabelApp] != app || existing.Labels[LabelVolume] != volume.Name {
+			DockerLogger.Errorf("Docker volume %s exists but is not volume %s of app %s", name, volume.Name, app)
+			return fmt.Errorf("Docker volume %s exists and does not belong to volume %s of app %s", name, volume.Name, app)
+		}
+		if volume.Driver != "" && existing.Driver != volume.Driver {
+			DockerLogger.Warnf("Docker volume %s uses driver %s instead of %s, keeping it", name, existing.Driver, volume.Driver)
+		}
+		return nil
+	}
+
+	labels := make(map[string]string)
+	for key, value := range volume.Labels {
+		labels[key] = value
+	}
+	labels[LabelApp] = app
+	labels[LabelVolume] = volume.Name
+
+	DockerLogger.Infof("Creating docker volume %s", name)
+	_, err := c.dockerCli.CreateVolume(DockerClient.CreateVolumeOptions{
+		Name: name,
+		Driver: volume.Driver,
+		DriverOpts: volume.DriverOpts,
+		Labels: labels,
+	})
+	if err != nil {
+		DockerLogger.Errorf("Creating docker volume %s - failed: %s", name, err)
+		return err
+	}
+	return nil
+}
+
+/* The named volumes we created for the app */
+func (c *DockerContainerEngine) appVolumes(app string) ([]DockerClient.Volume, error) {
+	return c.dockerCli.ListVolumes(DockerClient.ListVolumesOptions{Filters: map[string][]string{
+		"label": {LabelApp + "=" + app, LabelVolume},
+	}})
+}
+
+/* Deletes the named volumes of the app, the containers using them have to be removed first */
+func (c *DockerContainerEngine) RemoveVolumes(app string) ([]string, error) {
+	volumes, err := c.appVolumes(app)
+	if err != nil {
+		DockerLogger.Errorf("Listing docker volumes of %s failed: %s", app, err)
+		return nil, err
+	}
+
+	removed := make([]string, 0)
+	var lastErr error
+	for _, volume := range volumes {
+		DockerLogger.Infof("Removing docker volume %s", volume.Name)
+		if err := c.dockerCli.RemoveVolume(volume.Name); err != nil {
+			DockerLogger.Errorf("Removing docker volume %s - failed: %s", volume.Name, err)
+			lastErr = err
+			continue
+		}
+		removed = append(removed, volume.Name)
+	}
+	return removed, lastErr
+}
+
+/* Size and users of every named volume of the app, sizes are the last ones measured by
+MeasureVolumes and -1 until a volume was measured */
+func (c *DockerContainerEngine) VolumeUsage(app string) ([]model.VolumeUsage, error) {
+	volumes, err := c.appVolumes(app)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]model.VolumeUsage, 0)
+	for _, volume := range volumes {
+		usage := model.VolumeUsage{
+			Name: volume.Labels[LabelVolume],
+			DockerName: volume.Name,
+			Driver: volume.Driver,
+			Mountpoint: volume.Mountpoint,
+			Size: -1,
+		}
+		c.mutex.Lock()
+		if size, ok := c.volumeSizes[volume.Name]; ok {
+			usage.Size = size
+		}
+		c.mutex.Unlock()
+		containers, err := c.dockerCli.ListContainers(DockerClient.ListContainersOptions{All: true, Filters: map[string][]string{"volume": {volume.Name}}})
+		if err == nil {
+			usage.Containers = len(containers)
+		}
+		ret = append(ret, usage)
+	}
+	return ret, nil
+}
+
+/* Measures the size of our local volumes every volumeMeasureInterval, it never returns */
+func (c *DockerContainerEngine) MeasureVolumes() {
+	c.measureVolumes()
+	ticker := time.NewTicker(volumeMeasureInterval)
+	for range ticker.C {
+		c.measureVolumes()
+	}
+}
+
+func (c *DockerContainerEngine) measureVolumes() {
+	volumes, err := c.dockerCli.ListVolumes(DockerClient.ListVolumesOptions{Filters: map[string][]string{"label": {LabelVolume}}})
+	if err != nil {
+		DockerLogger.Warnf("Listing docker volumes failed: %s", err)
+		return
+	}
+
+	/* Walk without the lock, volumes can be large */
+	sizes := make(map[string]int64)
+	for _, volume := range volumes {
+		if volume.Driver == "local" {
+			sizes[volume.Name] = directorySize(volume.Mountpoint)
+		}
+	}
+
+	c.mutex.Lock()
+	c.volumeSizes = sizes
+	c.mutex.Unlock()
+}
+
+/* Bytes of the regular files below path, -1 if it cannot be read */
+func directorySize(path string) int64 {
+	var size int64
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return -1
+	}
+	return size
+}
+
+/* The docker binds of the volume mappings of the app */
+func volumeBinds(app string, mappings []model.VolumeMapping) []string {
+	binds := make([]string, 0)
+	for _, mapping := range mappings {
+		source := mapping.HostPath
+		if mapping.Volume != "" {
+			source = VolumeName(app, mapping.Volume)
+		}
+		bind := source + ":" + mapping.ContainerPath
+		if mapping.ReadOnly {
+			bind += ":ro"
+		}
+		binds = append(binds, bind)
+	}
+	return binds
+}
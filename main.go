@@ -37,10 +37,31 @@ func main() {
 	var hostId = flag.String("hostid", "host1", "Host Identifier")
 	var checkInInterval = flag.Int("interval", 60, "Check in interval")
 	var trainerUri = flag.String("traineruri", "http://localhost:5001", "Trainer Uri")
+	var dataDir = flag.String("datadir", "/var/lib/orcahostd", "Directory for persisted state")
+	var filesDir = flag.String("filesdir", "", "Directory for the files of the apps, <datadir>/files if empty")
+	var removeOrphans = flag.Bool("removeorphans", false, "Remove orphaned containers at startup instead of reporting them")
+	var workers = flag.Int("workers", 4, "Number of changes applied in parallel")
+	var portRange = flag.String("portrange", "20000-29999", "Host ports handed out for auto port mappings")
+	var keepImages = flag.Int("keepimages", client.DefaultKeepImages, "Versions per app whose images are kept")
+	var diskPressure = flag.Int("diskpressure", client.DefaultDiskPressure, "Disk usage in percent at which old images are removed")
 	flag.Parse()
 
+	ports, err := client.ParsePortRange((*portRange))
+	if err != nil {
+		MainLogger.Fatalf("%s", err)
+	}
+	options := client.Options{
+		DataDir: (*dataDir),
+		FilesDir: (*filesDir),
+		RemoveOrphans: (*removeOrphans),
+		Workers: (*workers),
+		PortRange: ports,
+		HostId: (*hostId),
+		KeepImages: (*keepImages),
+		DiskPressure: (*diskPressure),
+	}
 	client := client.Client{}
-	client.Init()
+	client.Init(options)
 
 	logsTicker := time.NewTicker(time.Duration(10 * time.Second))
 	go func () {
@@ -52,7 +73,11 @@ func main() {
 	trainerTicker := time.NewTicker(time.Duration((*checkInInterval)) * time.Second)
 	func () {
 		for {
-			<- trainerTicker.C
+			/* Check in early when changes finish so the trainer hears about them quickly */
+			select {
+			case <- trainerTicker.C:
+			case <- client.ChangesDone():
+			}
 			CallTrainer((*trainerUri), (*hostId), &client)
 		}
 	}()
@@ -65,13 +90,16 @@ func CallTrainer(trainerUri string, hostId string, client *client.Client) {
 	state := client.GetAppState()
 
 	for _, object := range state {
-		object.Application.Metrics = metrics[object.Name]
+		object.Application.Metrics = metrics[object.DockerAppId]
 	}
 
 	dataPackage := model.HostCheckinDataPackage{
 		State: state,
-		ChangesApplied: client.GetChangeLog(),
+		ChangeResults: client.GetChangeLog(),
 		HostMetrics: hostMetrics,
+		Orphans: client.GetOrphans(),
+		Pulls: client.GetPullProgress(),
+		Volumes: client.GetVolumeUsage(),
 	}
 
 	b := new(bytes.Buffer)
@@ -94,12 +122,7 @@ func CallTrainer(trainerUri string, hostId string, client *client.Client) {
 			if err := json.Unmarshal(body, &changes); err != nil {
 				MainLogger.Errorf("Failed to parse response - %s HTTP_BODY: %s", err, string(body))
 			} else {
-
-				/* This seems a bit funny, but since we are only dealing with one change at a time this
-				speeds things up. */
-				if client.HandleRequestedChanges(changes) {
-					CallTrainer(trainerUri, hostId, client)
-				}
+				client.HandleRequestedChanges(changes)
 			}
 		}
 	}
@@ -19,24 +19,118 @@ along with Orca.  If not, see <http://www.gnu.org/licenses/>.
 
 package model
 
+import "time"
+
 type Application struct {
 	Name     string
 	State    string
 	Version  string
 	ChangeId string
 	Metrics  Metric
+	Rollback *Rollback
+	/* The latest check results, oldest first */
+	CheckResults []CheckResult
+	/* Set when the image defines a HEALTHCHECK */
+	DockerHealth *DockerHealth
+}
+
+/* Set when a failed deploy left the application on its previous version */
+type Rollback struct {
+	ChangeId        string
+	FailedVersion   string
+	FailedState     string
+	RestoredVersion string
+	Time            time.Time
 }
 
 type ApplicationState struct {
 	DockerAppId string
 	Name        string
 	Application Application
+	/* Which of the replicas of the app this is, starting at 0 */
+	Instance    int
+	/* Stopped on request of the trainer, the container is kept */
+	Stopped     bool
+	/* Running and passing its readiness checks */
+	Ready       bool
+	/* The limits docker applied to the container */
+	Resources   Resources
+	/* The image the container runs and its repository digest, if it has one */
+	ImageId     string
+	Digest      string
+	/* The host ports the instance is bound to, with auto ports resolved */
+	Ports       []PortMapping
+	/* Restarts done by the supervisor and the exit code the container last died with */
+	Restarts     int
+	LastExitCode int
+	/* Restarted too often within the supervisor window */
+	CrashLoop    bool
 }
 
 type HostCheckinDataPackage struct {
 	State          []*ApplicationState
-	ChangesApplied map[string]bool
+	ChangeResults  map[string]ChangeResult
 	HostMetrics    Metric
+	Orphans        []OrphanContainer
+	/* The latest image pull of each app, keyed by app name */
+	Pulls          map[string]PullProgress
+	/* Named volumes, keyed by app name */
+	Volumes        map[string][]VolumeUsage
+}
+
+/* Values for PullProgress.Status */
+const (
+	PullPulling = "pulling"
+	PullDone = "done"
+	PullFailed = "failed"
+)
+
+/* Progress of an image pull, bytes count downloaded layer data */
+type PullProgress struct {
+	Image      string
+	Status     string
+	Error      string
+	Layers     int
+	LayersDone int
+	BytesDone  int64
+	BytesTotal int64
+	Started    time.Time
+	Updated    time.Time
+}
+
+/* Values for ChangeResult.Status */
+const (
+	ChangePending    = "pending"
+	ChangeApplying   = "applying"
+	ChangeSucceeded  = "succeeded"
+	ChangeFailed     = "failed"
+	ChangeRolledBack = "rolled_back"
+)
+
+/* Values for ChangeResult.Phase, where a failed change went wrong */
+const (
+	PhasePull   = "pull"
+	PhaseCreate = "create"
+	PhaseStart  = "start"
+	PhaseCheck  = "check"
+)
+
+/* What happened to a change the trainer sent, reported for every change the host has seen */
+type ChangeResult struct {
+	Status   string
+	Error    string
+	Phase    string
+	Started  time.Time
+	Finished time.Time
+	Attempts int
+}
+
+/* A labelled container found on the host that no application state claims */
+type OrphanContainer struct {
+	DockerAppId string
+	Name        string
+	Version     string
+	ChangeId    string
 }
 
 type Change struct {
@@ -44,6 +138,8 @@ type Change struct {
 	Type   string
 	Name 	string
 	Version string
+	Signal  string /* For signal_application, a name like SIGHUP or a number */
+	Purge   bool   /* For remove_application, also delete the named volumes of the app */
 
 	AppConfig VersionConfig
 }
@@ -55,22 +151,52 @@ type DockerConfig struct {
 	Server     string
 	Tag        string
 	Repository string
-	Reference  string
+	Reference  string /* A digest like sha256:..., pins the image instead of Tag */
 }
 
 type PortMapping struct {
+	/* Empty or "auto" lets the host pick a free port from its port range */
 	HostPort      string
 	ContainerPort string
+	/* Only reachable from the app's networks, the port is exposed but not bound on the host */
+	Internal      bool
 }
 
+/* Mounts either a host path or, when Volume is set, a named volume of the config */
 type VolumeMapping struct {
 	HostPath      string
 	ContainerPath string
+	Volume        string
+	ReadOnly      bool
+}
+
+/* A docker volume owned by the app. It is created on the first deploy, survives version
+upgrades and is only deleted when the app is removed with Purge. */
+type NamedVolume struct {
+	Name       string
+	Driver     string            /* Empty means docker's local driver */
+	DriverOpts map[string]string
+	Labels     map[string]string
 }
 
+/* What a named volume of an app takes up on the host */
+type VolumeUsage struct {
+	Name       string
+	DockerName string
+	Driver     string
+	Mountpoint string
+	Size       int64 /* Bytes, -1 when the driver keeps the data somewhere we cannot measure */
+	Containers int   /* Containers, running or not, that mount the volume */
+}
+
+/* A file written on the host and mounted read only into the container */
 type File struct {
+	/* Relative to /orcatmp in the container, only used when ContainerPath is empty */
 	HostPath           string
+	ContainerPath      string
 	Base64FileContents string
+	Mode               string /* Octal like 0640, 0644 if empty */
+	Owner              string /* Numeric uid or uid:gid, empty keeps the owner of orcahostd */
 }
 
 type EnvironmentVariable struct {
@@ -78,19 +204,140 @@ type EnvironmentVariable struct {
 	Value string
 }
 
+/* Docker values for DockerHealth.Status */
+const (
+	DockerHealthStarting = "starting"
+	DockerHealthHealthy = "healthy"
+	DockerHealthUnhealthy = "unhealthy"
+)
+
+/* The state of the HEALTHCHECK of an image as docker reports it */
+type DockerHealth struct {
+	Status        string
+	FailingStreak int
+	Log           []CheckResult /* The last runs docker keeps, oldest first */
+}
+
+/* Outcome of one run of a check */
+type CheckResult struct {
+	Type   string
+	Goal   string
+	Passed bool
+	Output string /* Output of exec checks, cut to the last 4k */
+	Error  string
+	Time   time.Time
+	Latency int64 /* Milliseconds the check took */
+}
+
+/* Values for ApplicationChecks.Kind */
+const (
+	/* Gates the deploy, not run afterwards */
+	CheckStartup = "startup"
+	/* Failing marks the instance not ready, it is not restarted */
+	CheckReadiness = "readiness"
+	/* Failing makes the instance checks_failed and lets the supervisor restart it */
+	CheckLiveness = "liveness"
+)
+
 type ApplicationChecks struct {
-	Type string /* Either HTTP or TCP */
-	Goal  string /* Either a port or uri */
+	Kind string /* Empty means the check gates the deploy and is a liveness check afterwards */
+	Type string /* Either HTTP, TCP or EXEC */
+	Goal  string /* Either a port or uri, for exec checks a shell command. May use {{.IP}}, {{.HostPort "8080"}} and {{.HostId}} */
+	Command []string /* Exec only, run without a shell instead of Goal */
+
+	Timeout          int /* Seconds a single attempt may take, 0 means 5 */
+	Interval         int /* Seconds between attempts, 0 means 6 while deploying and 10 afterwards */
+	FailureThreshold int /* Failed attempts before the check fails, 0 means 10 while deploying, afterwards 3 in a row or 1 for readiness checks */
+	SuccessThreshold int /* Consecutive passed attempts before the check passes, 0 means 1 */
+
+	/* HTTP only */
+	StatusCodes  []string          /* Accepted status codes like 200 or ranges like 200-299, empty means 200 */
+	Method       string            /* GET if empty */
+	Headers      map[string]string
+	BodyContains string            /* The response body must contain this */
+	BodyRegex    string            /* The response body must match this */
 }
 
 type VersionConfig struct {
 	DockerConfig	     DockerConfig
 	PortMappings         []PortMapping
 	VolumeMappings       []VolumeMapping
+	Volumes              []NamedVolume
 	EnvironmentVariables []EnvironmentVariable
 	Files                []File
 	Version 	     string
 	Checks               []ApplicationChecks
+	UpdateStrategy       string /* Either start_first (default) or stop_first */
+	AutoRollback         bool   /* Redeploy the last known good version when this one fails */
+	Replicas             int    /* Number of containers to run, 0 means 1 */
+	Supervisor           *SupervisorPolicy /* Restart dying or failing instances locally, nil means the trainer handles them */
+	Resources            Resources
+
+	/* Runtime options, empty means the default of the image or of docker */
+	Command              []string
+	Entrypoint           []string
+	User                 string
+	WorkingDir           string
+	Hostname             string
+	ExtraHosts           []string          /* host:ip entries added to /etc/hosts */
+	Dns                  []string
+	Labels               map[string]string /* Put on the container next to the orca.* labels, which cannot be overridden */
+	RestartPolicy        *RestartPolicy    /* Docker's own restart policy, not to be combined with Supervisor */
+	Networks             []NetworkAttachment /* User defined networks, the app is reachable on them under its name */
+}
+
+/* A user defined bridge network the app joins. The network is created when missing and
+removed once no container uses it anymore. */
+type NetworkAttachment struct {
+	Name    string
+	Aliases []string /* DNS names next to the app name */
+}
+
+/* Values for RestartPolicy.Name */
+const (
+	RestartNo = "no"
+	RestartAlways = "always"
+	RestartUnlessStopped = "unless-stopped"
+	RestartOnFailure = "on-failure"
+)
+
+type RestartPolicy struct {
+	Name              string
+	MaximumRetryCount int /* on-failure only, 0 means no limit */
+}
+
+/* Limits of a container, zero values mean no limit */
+type Resources struct {
+	Memory            int64  /* Bytes */
+	MemoryReservation int64  /* Bytes, a soft limit below Memory */
+	MemorySwap        int64  /* Bytes of memory and swap together, -1 means unlimited swap */
+	CpuShares         int64  /* Relative weight, docker uses 1024 if not set */
+	CpuQuota          int64  /* Microseconds of CPU time per CpuPeriod */
+	CpuPeriod         int64  /* Microseconds, docker uses 100000 if not set */
+	CpusetCpus        string /* CPUs the container may run on, like 0-3 or 0,2 */
+	PidsLimit         int64
+	Ulimits           []Ulimit
+	BlkioWeight       int64  /* Relative block IO weight, 10 to 1000 */
+}
+
+type Ulimit struct {
+	Name string /* Like nofile or nproc */
+	Soft int64
+	Hard int64
+}
+
+/* What the host can give to containers */
+type HostCapacity struct {
+	Memory int64 /* Bytes */
+	Cpus   int
+}
+
+/* How the host restarts instances that died or failed their checks */
+type SupervisorPolicy struct {
+	MaxRestarts    int /* Restarts within Window before the app counts as crash looping, 0 means 5 */
+	Window         int /* Seconds, 0 means 300 */
+	InitialBackoff int /* Seconds before restarting again, doubled for every restart within Window, 0 means 1 */
+	MaxBackoff     int /* Seconds, 0 means 300 */
 }
 
 type Metric struct {